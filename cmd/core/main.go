@@ -11,9 +11,11 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/gerladeno/homie-core/pkg/blob"
 	"github.com/gerladeno/homie-core/pkg/chat"
 
 	"github.com/gerladeno/homie-core/internal"
@@ -30,23 +32,47 @@ const httpPort = 3001
 var publicSigningKey []byte
 
 var (
-	version = `0.0.0`
-	pgDSN   = os.Getenv("PG_DSN")
-	domain  = os.Getenv("APP_DOMAIN")
+	version    = `0.0.0`
+	pgDSN      = os.Getenv("PG_DSN")
+	domain     = os.Getenv("APP_DOMAIN")
+	undoWindow = os.Getenv("UNDO_WINDOW")
+	dislikeTTL = os.Getenv("DISLIKE_TTL")
+	photosDir  = os.Getenv("PHOTOS_DIR")
+	photosURL  = os.Getenv("PHOTOS_URL")
+	maxPhotos  = os.Getenv("MAX_PHOTOS")
+	metroStops = os.Getenv("METRO_STOPS")
 )
 
 func main() {
 	log := logging.GetLogger(true)
 	ctx := context.Background()
-	store, err := storage.New(ctx, log, pgDSN)
+	var opts []storage.Option
+	if dislikeTTL != "" {
+		opts = append(opts, storage.WithRelationTTL(storage.Disliked, mustGetDuration(dislikeTTL, 0)))
+	}
+	opts = append(opts, storage.WithStationStops(mustGetInt(metroStops, storage.DefaultStationStops)))
+	store, err := storage.New(ctx, log, pgDSN, opts...)
 	if err != nil {
 		log.Panicf("err initing pg: %v", err)
 	}
 	if err = store.Migrate(); err != nil {
 		log.Panicf("err migrating pg: %v", err)
 	}
-	chatServer := chat.NewServer()
-	app := internal.NewApp(log, store, chatServer)
+	chatServer := chat.NewServer(store, store.IsMutualLike)
+	if photosDir == "" {
+		photosDir = "photos"
+	}
+	if photosURL == "" {
+		photosURL = fmt.Sprintf("https://%s/public/v1/photos/files", domain)
+	}
+	blobs, err := blob.NewLocalStore(photosDir, photosURL)
+	if err != nil {
+		log.Panicf("err initing photo store: %v", err)
+	}
+	app := internal.NewApp(log, store, chatServer, blobs,
+		internal.WithUndoWindow(mustGetDuration(undoWindow, internal.DefaultUndoWindow)),
+		internal.WithMaxPhotos(mustGetInt(maxPhotos, internal.DefaultMaxPhotos)),
+	)
 	router := rest.NewRouter(log, app, mustGetPublicKey(publicSigningKey), domain, version)
 	if err = startServer(ctx, router, log); err != nil {
 		log.Panic(err)
@@ -81,6 +107,28 @@ func startServer(ctx context.Context, router http.Handler, log *logrus.Logger) e
 	return s.Shutdown(gfCtx)
 }
 
+func mustGetDuration(val string, defaultValue time.Duration) time.Duration {
+	if val == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func mustGetInt(val string, defaultValue int) int {
+	if val == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
 func mustGetPublicKey(keyBytes []byte) *rsa.PublicKey {
 	if len(keyBytes) == 0 {
 		panic("file public.pub is missing or invalid")
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+)
+
+// LookupConfig returns the config of any user to a staff member, the lookup is audited.
+func (a *App) LookupConfig(ctx context.Context, actor, uuid string) (*models.Config, error) {
+	config, err := a.GetConfig(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditViewConfig, Target: uuid}
+	if err = a.store.Audit(ctx, &entry); err != nil {
+		return nil, fmt.Errorf("err looking up config: %w", err)
+	}
+	return config, nil
+}
+
+// SetProfileHidden hides the profile of uuid from others whatever its settings are, or shows it again.
+func (a *App) SetProfileHidden(ctx context.Context, actor, uuid string, hidden bool) error {
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditUnhideProfile}
+	if hidden {
+		entry.Action = models.AuditHideProfile
+	}
+	err := a.store.SetForceHidden(ctx, uuid, hidden, &entry)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrConfigNotFound
+	default:
+		return fmt.Errorf("err hiding profile: %w", err)
+	}
+	return nil
+}
+
+// ListAllRegions returns all regions to staff, deleted ones included.
+func (a *App) ListAllRegions(ctx context.Context) ([]*models.Region, error) {
+	regions, err := a.store.ListAllRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("err listing regions: %w", err)
+	}
+	return regions, nil
+}
+
+func (a *App) GetRegion(ctx context.Context, id int64) (*models.Region, error) {
+	region, err := a.store.GetRegion(ctx, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrRegionNotFound):
+		return nil, common.ErrRegionNotFound
+	default:
+		return nil, fmt.Errorf("err getting region: %w", err)
+	}
+	return region, nil
+}
+
+func (a *App) CreateRegion(ctx context.Context, actor string, region *models.Region) error {
+	entry, err := regionAuditEntry(actor, models.AuditCreateRegion, region)
+	if err != nil {
+		return err
+	}
+	err = a.store.CreateRegion(ctx, region, entry)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrInvalidRegionParent):
+		return common.ErrInvalidRegionParent
+	default:
+		return fmt.Errorf("err creating region: %w", err)
+	}
+	return nil
+}
+
+func (a *App) UpdateRegion(ctx context.Context, actor string, region *models.Region) error {
+	entry, err := regionAuditEntry(actor, models.AuditUpdateRegion, region)
+	if err != nil {
+		return err
+	}
+	err = a.store.UpdateRegion(ctx, region, entry)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrRegionNotFound), errors.Is(err, common.ErrInvalidRegionParent):
+		return err
+	default:
+		return fmt.Errorf("err updating region: %w", err)
+	}
+	return nil
+}
+
+// DeleteRegion deletes the region with the regions inside it, users who chose them aren't matched by them anymore.
+func (a *App) DeleteRegion(ctx context.Context, actor string, id int64) error {
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditDeleteRegion}
+	err := a.store.DeleteRegion(ctx, id, &entry)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrRegionNotFound):
+		return common.ErrRegionNotFound
+	default:
+		return fmt.Errorf("err deleting region: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns the audit log of staff actions, the latest first.
+func (a *App) ListAudit(ctx context.Context, limit, offset int64) ([]*models.AuditEntry, error) {
+	entries, err := a.store.ListAudit(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("err listing audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// regionAuditEntry checks the region and records it as the details of the action.
+func regionAuditEntry(actor, action string, region *models.Region) (*models.AuditEntry, error) {
+	region.Name = strings.TrimSpace(region.Name)
+	if region.Name == "" || region.Boundary != nil && !region.Boundary.Valid() {
+		return nil, common.ErrInvalidRegion
+	}
+	details, err := json.Marshal(region)
+	if err != nil {
+		return nil, fmt.Errorf("err marshaling region: %w", err)
+	}
+	return &models.AuditEntry{Actor: actor, Action: action, Details: details}, nil
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/chat"
+)
+
+// Export writes everything stored about the owner of config to w as a zip of JSON documents: the config,
+// relations in both directions, listings, photos with their files and a document per chat. Entries are
+// written one by one and messages are read page by page, so nothing big is held in memory.
+func (a *App) Export(ctx context.Context, config *models.Config, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	if err := writeJSONEntry(zw, "config.json", config); err != nil {
+		return err
+	}
+
+	relations, err := a.store.ListRelations(ctx, config.UUID)
+	if err != nil {
+		return fmt.Errorf("err exporting relations: %w", err)
+	}
+	if err = writeJSONEntry(zw, "relations.json", relations); err != nil {
+		return err
+	}
+
+	listings, err := a.store.ListListings(ctx, config.UUID)
+	if err != nil {
+		return fmt.Errorf("err exporting listings: %w", err)
+	}
+	if err = writeJSONEntry(zw, "listings.json", listings); err != nil {
+		return err
+	}
+
+	photos, err := a.store.ListPhotos(ctx, config.UUID)
+	if err != nil {
+		return fmt.Errorf("err exporting photos: %w", err)
+	}
+	if err = writeJSONEntry(zw, "photos.json", photos); err != nil {
+		return err
+	}
+	for _, photo := range photos {
+		if err = a.exportPhotoFile(ctx, zw, photo.Key); err != nil {
+			return err
+		}
+	}
+
+	peers, err := a.chatServer.GetAllChats(ctx, config.UUID)
+	if err != nil {
+		return fmt.Errorf("err exporting chats: %w", err)
+	}
+	for _, peer := range peers {
+		if err = a.exportChat(ctx, zw, config.UUID, peer); err != nil {
+			return err
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("err finishing export: %w", err)
+	}
+	return nil
+}
+
+func (a *App) exportPhotoFile(ctx context.Context, zw *zip.Writer, key string) error {
+	r, err := a.blobs.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("err opening photo %s: %w", key, err)
+	}
+	defer r.Close()
+	// photos are already compressed, so they are only stored.
+	ew, err := zw.CreateHeader(&zip.FileHeader{Name: path.Join("photos", path.Base(key)), Method: zip.Store})
+	if err != nil {
+		return fmt.Errorf("err exporting photo %s: %w", key, err)
+	}
+	if _, err = io.Copy(ew, r); err != nil {
+		return fmt.Errorf("err exporting photo %s: %w", key, err)
+	}
+	return nil
+}
+
+// exportChat writes the messages with peer as a JSON array, encoding them as they are read.
+func (a *App) exportChat(ctx context.Context, zw *zip.Writer, uuid, peer string) error {
+	ew, err := zw.Create(path.Join("chats", peer+".json"))
+	if err != nil {
+		return fmt.Errorf("err exporting chat with %s: %w", peer, err)
+	}
+	if _, err = io.WriteString(ew, "["); err != nil {
+		return fmt.Errorf("err exporting chat with %s: %w", peer, err)
+	}
+	sep := ""
+	err = a.chatServer.EachMessage(ctx, uuid, peer, func(m *chat.Message) error {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(ew, sep); err != nil {
+			return err
+		}
+		sep = ","
+		_, err = ew.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("err exporting chat with %s: %w", peer, err)
+	}
+	if _, err = io.WriteString(ew, "]"); err != nil {
+		return fmt.Errorf("err exporting chat with %s: %w", peer, err)
+	}
+	return nil
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v interface{}) error {
+	ew, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("err exporting %s: %w", name, err)
+	}
+	if err = json.NewEncoder(ew).Encode(v); err != nil {
+		return fmt.Errorf("err exporting %s: %w", name, err)
+	}
+	return nil
+}
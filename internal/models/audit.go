@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Actions of staff recorded in the audit log.
+const (
+	AuditViewConfig    = "config.view"
+	AuditHideProfile   = "profile.hide"
+	AuditUnhideProfile = "profile.unhide"
+	AuditListReports   = "reports.list"
+	AuditResolveReport = "report.resolve"
+	AuditCreateRegion  = "region.create"
+	AuditUpdateRegion  = "region.update"
+	AuditDeleteRegion  = "region.delete"
+)
+
+// AuditEntry records that Actor did Action to Target, Details holds the action arguments.
+type AuditEntry struct {
+	ID      int64           `json:"id"`
+	Actor   string          `json:"actor"`
+	Action  string          `json:"action"`
+	Target  string          `json:"target"`
+	Details json.RawMessage `json:"details,omitempty"`
+	Created time.Time       `json:"created"`
+}
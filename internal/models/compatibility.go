@@ -0,0 +1,12 @@
+package models
+
+// Criteria names reported in Profile.MatchedCriteria. Matches are scored by the match_score function
+// of the database, so they can be ranked and paged there.
+const (
+	CriterionPrice     = "price"
+	CriterionRegions   = "regions"
+	CriterionAge       = "age"
+	CriterionLifestyle = "lifestyle"
+	CriterionStations  = "stations"
+	CriterionDistance  = "distance"
+)
@@ -0,0 +1,69 @@
+package models
+
+import "encoding/json"
+
+// MaxDistanceKm limits SearchCriteria.MaxDistance.
+const MaxDistanceKm = 100
+
+// Point is a place on the Earth, latitude and longitude are in degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+func (p *Point) validate(v *validator, field string) {
+	v.check(p.Valid(), field, "lat must be between -90 and 90, lon between -180 and 180")
+}
+
+// Boundary is a GeoJSON Polygon or MultiPolygon geometry outlining a region.
+type Boundary struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Valid tells whether the boundary is a Polygon or a MultiPolygon of closed rings of at least 4 valid positions.
+func (b *Boundary) Valid() bool {
+	switch b.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		return json.Unmarshal(b.Coordinates, &polygon) == nil && validPolygon(polygon)
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if json.Unmarshal(b.Coordinates, &polygons) != nil || len(polygons) == 0 {
+			return false
+		}
+		for _, polygon := range polygons {
+			if !validPolygon(polygon) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func validPolygon(rings [][][]float64) bool {
+	if len(rings) == 0 {
+		return false
+	}
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			return false
+		}
+		for _, position := range ring {
+			if len(position) < 2 || !(Point{Lat: position[1], Lon: position[0]}).Valid() {
+				return false
+			}
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return false
+		}
+	}
+	return true
+}
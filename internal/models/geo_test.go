@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundaryValid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		boundary string
+		valid    bool
+	}{
+		"polygon": {`{"type":"Polygon","coordinates":[[[37.5,55.7],[37.7,55.7],[37.7,55.8],[37.5,55.7]]]}`, true},
+		"multipolygon": {`{"type":"MultiPolygon","coordinates":[
+			[[[37.5,55.7],[37.7,55.7],[37.7,55.8],[37.5,55.7]]],
+			[[[36.8,55.3],[37.0,55.3],[37.0,55.4],[36.8,55.3]]]]}`, true},
+		"point":         {`{"type":"Point","coordinates":[37.5,55.7]}`, false},
+		"open ring":     {`{"type":"Polygon","coordinates":[[[37.5,55.7],[37.7,55.7],[37.7,55.8],[37.5,55.8]]]}`, false},
+		"short ring":    {`{"type":"Polygon","coordinates":[[[37.5,55.7],[37.7,55.7],[37.5,55.7]]]}`, false},
+		"out of range":  {`{"type":"Polygon","coordinates":[[[37.5,95.7],[37.7,55.7],[37.7,55.8],[37.5,95.7]]]}`, false},
+		"no rings":      {`{"type":"Polygon","coordinates":[]}`, false},
+		"no polygons":   {`{"type":"MultiPolygon","coordinates":[]}`, false},
+		"bad positions": {`{"type":"Polygon","coordinates":[["a","b","c","d"]]}`, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var boundary Boundary
+			require.NoError(t, json.Unmarshal([]byte(tc.boundary), &boundary))
+			require.Equal(t, tc.valid, boundary.Valid())
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+// Every lifestyle attribute has its own type of values, so a value of one attribute can't be given to another.
+type (
+	Smoking       int8
+	Pets          int8
+	SleepSchedule int8
+	Cleanliness   int8
+	Guests        int8
+)
+
+// Trait is a value of a lifestyle attribute, 0 means the user didn't specify it.
+type Trait interface {
+	Smoking | Pets | SleepSchedule | Cleanliness | Guests
+}
+
+const Unspecified = 0
+
+const (
+	NonSmoker Smoking = iota + 1
+	Smoker
+)
+
+const (
+	NoPets Pets = iota + 1
+	HasPets
+)
+
+const (
+	EarlyBird SleepSchedule = iota + 1
+	NightOwl
+)
+
+const (
+	Tidy Cleanliness = iota + 1
+	Relaxed
+)
+
+const (
+	RareGuests Guests = iota + 1
+	FrequentGuests
+)
+
+// Importance tells how a lifestyle preference is applied while searching.
+type Importance int8
+
+const (
+	DontCare Importance = iota
+	Prefer              // affects only the compatibility score
+	Must                // candidates not having the value are filtered out
+)
+
+type Lifestyle struct {
+	Smoking       Smoking       `json:"smoking"`
+	Pets          Pets          `json:"pets"`
+	SleepSchedule SleepSchedule `json:"sleep_schedule"`
+	Cleanliness   Cleanliness   `json:"cleanliness"`
+	Guests        Guests        `json:"guests"`
+}
+
+type Preference[T Trait] struct {
+	Value      T          `json:"value"`
+	Importance Importance `json:"importance"`
+}
+
+type LifestylePreferences struct {
+	Smoking       Preference[Smoking]       `json:"smoking"`
+	Pets          Preference[Pets]          `json:"pets"`
+	SleepSchedule Preference[SleepSchedule] `json:"sleep_schedule"`
+	Cleanliness   Preference[Cleanliness]   `json:"cleanliness"`
+	Guests        Preference[Guests]        `json:"guests"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Role tells whether the user looks for a room or offers one.
+type Role int8
+
+const (
+	Seeker Role = iota
+	ListingOwner
+)
+
+// RoleOf returns a pointer to r, Config.Role is nil when the role is not sent.
+func RoleOf(r Role) *Role {
+	return &r
+}
+
+type Listing struct {
+	ID            int64     `json:"id"`
+	UUID          string    `json:"uuid,omitempty"`
+	RegionID      int64     `json:"region_id"`
+	Location      *Point    `json:"location,omitempty"`
+	Rent          float64   `json:"rent"`
+	Rooms         int8      `json:"rooms"`
+	AvailableFrom time.Time `json:"available_from"`
+	Photos        []string  `json:"photos"`
+	Description   string    `json:"description"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// MetroLine is a metro line with its stations in the order the trains follow them.
+// The last station of a circular line neighbours the first one.
+type MetroLine struct {
+	ID       int64      `json:"id"`
+	Name     string     `json:"name"`
+	Color    string     `json:"color"`
+	Circular bool       `json:"circular,omitempty"`
+	Stations []*Station `json:"stations"`
+}
+
+// Station is a metro station, RegionID is the region it is in, if it is in any.
+type Station struct {
+	ID       int64  `json:"id"`
+	LineID   int64  `json:"line_id"`
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+	RegionID *int64 `json:"region_id,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/gerladeno/homie-core/pkg/common"
+)
+
+// Page describes a chunk of a list: the total number of items and the cursor of the next chunk,
+// empty if it is the last one.
+type Page struct {
+	Count      int64
+	NextCursor string
+}
+
+// Cursor points to the last item of a page. Lists ordered by time use Timestamp, matches use Score,
+// UUID breaks ties. It is passed to clients as an opaque string.
+type Cursor struct {
+	Timestamp time.Time `json:"t,omitempty"`
+	Score     float64   `json:"s,omitempty"`
+	UUID      string    `json:"u"`
+}
+
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c) //nolint:errchkjson
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor received from a client, an empty string means the first page.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil //nolint:nilnil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, common.ErrInvalidCursor
+	}
+	var c Cursor
+	if err = json.Unmarshal(b, &c); err != nil || c.UUID == "" {
+		return nil, common.ErrInvalidCursor
+	}
+	return &c, nil
+}
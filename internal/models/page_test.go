@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		c := Cursor{Timestamp: time.Date(2022, 5, 22, 17, 59, 0, 123456000, time.UTC), UUID: "first"}
+		decoded, err := DecodeCursor(c.Encode())
+		require.NoError(t, err)
+		require.True(t, c.Timestamp.Equal(decoded.Timestamp))
+		require.Equal(t, c.UUID, decoded.UUID)
+	})
+	t.Run("first page", func(t *testing.T) {
+		decoded, err := DecodeCursor("")
+		require.NoError(t, err)
+		require.Nil(t, decoded)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := DecodeCursor("not a cursor")
+		require.ErrorIs(t, err, common.ErrInvalidCursor)
+		_, err = DecodeCursor((&Cursor{Score: 0.5}).Encode())
+		require.ErrorIs(t, err, common.ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gerladeno/homie-core/pkg/common"
+)
+
+// FieldMask is a set of JSON paths of Config fields, like "personal.age" or "criteria.price_range.from".
+// A path covers all the fields nested into it, a nil mask covers every field.
+type FieldMask map[string]struct{}
+
+func (m FieldMask) Has(path string) bool {
+	if m == nil {
+		return true
+	}
+	for {
+		if _, ok := m[path]; ok {
+			return true
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+// Touches tells whether the field at path or any field nested into it is covered by the mask.
+func (m FieldMask) Touches(path string) bool {
+	if m.Has(path) {
+		return true
+	}
+	for p := range m {
+		if strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// PatchConfig applies a JSON Merge Patch (RFC 7396) to config and returns the paths the patch touched.
+// UUIDs can't be patched.
+func PatchConfig(config *Config, patch []byte) (*Config, FieldMask, error) {
+	doc, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	var target, p map[string]interface{}
+	if err = json.Unmarshal(doc, &target); err != nil {
+		return nil, nil, err
+	}
+	if err = json.Unmarshal(patch, &p); err != nil || p == nil {
+		return nil, nil, common.ErrInvalidPatch
+	}
+	mask := make(FieldMask)
+	mergePatch(target, p, "", mask)
+	if doc, err = json.Marshal(target); err != nil {
+		return nil, nil, err
+	}
+	var result Config
+	if err = json.Unmarshal(doc, &result); err != nil {
+		return nil, nil, common.ErrInvalidPatch
+	}
+	result.SetUUID(config.UUID)
+	return &result, mask, nil
+}
+
+func mergePatch(target, patch map[string]interface{}, prefix string, mask FieldMask) {
+	for key, value := range patch {
+		path := prefix + key
+		if value == nil {
+			delete(target, key)
+			mask[path] = struct{}{}
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			target[key] = value
+			mask[path] = struct{}{}
+			continue
+		}
+		current, ok := target[key].(map[string]interface{})
+		if !ok {
+			// the object is created from scratch, so every field of it is set
+			current = make(map[string]interface{})
+			mask[path] = struct{}{}
+		}
+		mergePatch(current, object, path+".", mask)
+		target[key] = current
+	}
+}
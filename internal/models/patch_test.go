@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchConfig(t *testing.T) {
+	config := Config{
+		Personal: &Personal{Username: "chuvak", Gender: Male, Age: 26},
+		Criteria: &SearchCriteria{Regions: []int64{1, 2}, PriceRange: NewRange(20000, 40000)},
+	}
+	config.SetUUID("first")
+
+	t.Run("merge", func(t *testing.T) {
+		patched, mask, err := PatchConfig(&config, []byte(`{"personal":{"age":27},"criteria":{"price_range":{"to":null}}}`))
+		require.NoError(t, err)
+		require.Equal(t, "chuvak", patched.Personal.Username)
+		require.Equal(t, int8(27), patched.Personal.Age)
+		require.Equal(t, 20000.0, *patched.Criteria.PriceRange.From)
+		require.Nil(t, patched.Criteria.PriceRange.To)
+		require.Equal(t, []int64{1, 2}, patched.Criteria.Regions)
+		require.True(t, mask.Has("personal.age"))
+		require.True(t, mask.Has("criteria.price_range.to"))
+		require.False(t, mask.Has("personal.username"))
+		require.False(t, mask.Has("criteria.price_range.from"))
+		require.False(t, mask.Has("criteria.regions"))
+	})
+	t.Run("new object", func(t *testing.T) {
+		patched, mask, err := PatchConfig(&config, []byte(`{"settings":{"theme":1}}`))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), patched.Settings.Theme)
+		require.Equal(t, "first", patched.Settings.UUID)
+		require.True(t, mask.Has("settings.theme"))
+		require.True(t, mask.Has("settings.uuid"))
+	})
+	t.Run("uuid is kept", func(t *testing.T) {
+		patched, _, err := PatchConfig(&config, []byte(`{"uuid":"second","personal":{"uuid":"second"}}`))
+		require.NoError(t, err)
+		require.Equal(t, "first", patched.UUID)
+		require.Equal(t, "first", patched.Personal.UUID)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := PatchConfig(&config, []byte(`[1, 2]`))
+		require.ErrorIs(t, err, common.ErrInvalidPatch)
+		_, _, err = PatchConfig(&config, []byte(`{"personal":{"age":"old"}}`))
+		require.ErrorIs(t, err, common.ErrInvalidPatch)
+	})
+	t.Run("nil mask", func(t *testing.T) {
+		var mask FieldMask
+		require.True(t, mask.Has("personal.age"))
+	})
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	MaxPhotoSize   = 10 << 20   // bytes
+	MaxPhotoPixels = 16_000_000 // checked before decoding, a decoded photo takes up to 4 bytes per pixel
+	ThumbnailSize  = 320        // pixels on the longer side
+)
+
+// Photo is an image in the user's gallery, the photo at position 0 is the avatar.
+type Photo struct {
+	ID           int64     `json:"id"`
+	UUID         string    `json:"uuid"`
+	Position     int       `json:"position"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Created      time.Time `json:"created"`
+}
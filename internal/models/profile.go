@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"fmt"
+	"time"
 )
 
 type Gender int8
@@ -35,9 +36,18 @@ const (
 
 type Config struct {
 	UUID     string          `json:"uuid,omitempty"`
+	Role     *Role           `json:"role,omitempty"`
 	Personal *Personal       `json:"personal,omitempty"`
 	Criteria *SearchCriteria `json:"criteria,omitempty"`
 	Settings *Settings       `json:"settings,omitempty"`
+	// ForceHidden is set by moderators and hides the profile whatever Settings.Visibility is,
+	// users can't change it.
+	ForceHidden bool `json:"force_hidden,omitempty"`
+}
+
+// IsListingOwner tells whether the user may publish listings.
+func (c *Config) IsListingOwner() bool {
+	return c.Role != nil && *c.Role == ListingOwner
 }
 
 func (c *Config) SetUUID(uuid string) {
@@ -54,42 +64,73 @@ func (c *Config) SetUUID(uuid string) {
 }
 
 type Profile struct {
-	UUID     string          `json:"uuid,omitempty"`
-	Personal *Personal       `json:"personal,omitempty"`
-	Criteria *SearchCriteria `json:"criteria,omitempty"`
+	UUID            string          `json:"uuid,omitempty"`
+	Personal        *Personal       `json:"personal,omitempty"`
+	Criteria        *SearchCriteria `json:"criteria,omitempty"`
+	Score           float64         `json:"score,omitempty"`
+	MatchedCriteria []string        `json:"matched_criteria,omitempty"`
+	Listings        []*Listing      `json:"listings,omitempty"`
 }
 
 type Personal struct {
-	UUID       string `json:"uuid,omitempty"`
-	Username   string `json:"username"`
-	AvatarLink string `json:"avatar_link"`
-	Gender     Gender `json:"gender"`
-	Age        int8   `json:"age"`
+	UUID     string `json:"uuid,omitempty"`
+	Username string `json:"username"`
+	// AvatarLink is the URL of the first photo of the gallery, it can't be set with the config
+	AvatarLink string    `json:"avatar_link"`
+	Gender     Gender    `json:"gender"`
+	Age        int8      `json:"age"`
+	Lifestyle  Lifestyle `json:"lifestyle"`
 }
 
 type Relation struct {
-	UUID     string
-	Target   string
-	Relation int8
+	UUID     string `json:"uuid"`
+	Target   string `json:"target"`
+	Relation int8   `json:"relation"`
 }
 
+type LikeResult struct {
+	Match bool `json:"match"`
+}
+
+// Visibility tells whether other users are shown the profile in their matches, admirers and liked lists.
+// Chats keep working whatever it is.
+type Visibility int8
+
+const (
+	Active Visibility = iota
+	Paused
+	// Hidden profiles are shown again once HiddenUntil passes.
+	Hidden
+)
+
 type Settings struct {
-	UUID  string `json:"uuid,omitempty"`
-	Theme int64  `json:"theme"`
+	UUID        string     `json:"uuid,omitempty"`
+	Theme       int64      `json:"theme"`
+	Visibility  Visibility `json:"visibility"`
+	HiddenUntil *time.Time `json:"hidden_until,omitempty"`
 }
 
 type SearchCriteria struct {
-	UUID       string  `json:"uuid,omitempty"`
-	Regions    []int64 `json:"regions"`
-	PriceRange Range   `json:"price_range"`
-	Gender     Gender  `json:"gender"`
-	AgeRange   Range   `json:"age_range"`
+	UUID     string  `json:"uuid,omitempty"`
+	Regions  []int64 `json:"regions"`
+	Stations []int64 `json:"stations"`
+	// Location and MaxDistance find users and listings around a place as an alternative to regions.
+	Location    *Point               `json:"location,omitempty"`
+	MaxDistance *float64             `json:"max_distance_km,omitempty"`
+	PriceRange  Range                `json:"price_range"`
+	Gender      Gender               `json:"gender"`
+	AgeRange    Range                `json:"age_range"`
+	Lifestyle   LifestylePreferences `json:"lifestyle"`
 }
 
+// Region is a city, an okrug or a district, ParentID is the region containing it.
 type Region struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          int64      `json:"id"`
+	ParentID    *int64     `json:"parent_id,omitempty"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Boundary    *Boundary  `json:"boundary,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type Range struct {
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// ReportReason tells why a user was reported.
+type ReportReason int8
+
+const (
+	Spam ReportReason = iota + 1
+	Harassment
+	FakeProfile
+	InappropriateContent
+	Scam
+	OtherReason
+)
+
+// Valid reports whether the reason is one of the known ones.
+func (r ReportReason) Valid() bool {
+	return r >= Spam && r <= OtherReason
+}
+
+// Resolution tells what a moderator did about a report.
+type Resolution int8
+
+const (
+	Unresolved Resolution = iota
+	Dismissed
+	ActionTaken
+)
+
+// Report is a complaint of UUID about Target waiting in the moderation queue until it is resolved.
+type Report struct {
+	ID         int64        `json:"id"`
+	UUID       string       `json:"uuid"`
+	Target     string       `json:"target"`
+	Reason     ReportReason `json:"reason"`
+	Comment    string       `json:"comment"`
+	Created    time.Time    `json:"created"`
+	Resolution Resolution   `json:"resolution"`
+	Note       string       `json:"note,omitempty"`
+	Resolved   *time.Time   `json:"resolved,omitempty"`
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gerladeno/homie-core/pkg/common"
+)
+
+const (
+	MaxAge            = 120
+	MaxUsernameLength = 64
+)
+
+// FieldError tells why a field is invalid, Field is the JSON path of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists all invalid fields of a config or a listing, it wraps common.ErrValidation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("%v: %s", common.ErrValidation, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return common.ErrValidation
+}
+
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) check(ok bool, field, message string) {
+	if !ok {
+		v.fields = append(v.fields, FieldError{Field: field, Message: message})
+	}
+}
+
+// Validate checks every part of the config present, regions and stations are the IDs of known regions
+// and metro stations. It returns nil or a *ValidationError.
+func (c *Config) Validate(regions, stations map[int64]struct{}) error {
+	return c.validate(regions, stations, nil)
+}
+
+// ValidatePatch works like Validate, but checks only the fields touched by mask.
+func (c *Config) ValidatePatch(regions, stations map[int64]struct{}, mask FieldMask) error {
+	return c.validate(regions, stations, mask)
+}
+
+func (c *Config) validate(regions, stations map[int64]struct{}, mask FieldMask) error {
+	v := validator{}
+	v.check(c.Role == nil || *c.Role == Seeker || *c.Role == ListingOwner, "role", "unknown role")
+	// a config without the role keeps the stored one, so a patch can't remove it
+	v.check(mask == nil || c.Role != nil, "role", "must not be null")
+	if c.Personal != nil {
+		c.Personal.validate(&v, "personal")
+	}
+	if c.Criteria != nil {
+		c.Criteria.validate(&v, "criteria", regions, stations)
+	}
+	if c.Settings != nil {
+		c.Settings.validate(&v, "settings")
+	}
+	var fields []FieldError
+	for _, f := range v.fields {
+		if mask == nil || mask.Touches(f.Field) {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// Validate checks the listing, regions are the IDs of known regions. It returns nil or a *ValidationError.
+func (l *Listing) Validate(regions map[int64]struct{}) error {
+	v := validator{}
+	_, ok := regions[l.RegionID]
+	v.check(ok, "region_id", fmt.Sprintf("unknown region %d", l.RegionID))
+	if l.Location != nil {
+		l.Location.validate(&v, "location")
+	}
+	v.check(l.Rent >= 0, "rent", "must not be negative")
+	v.check(l.Rooms > 0, "rooms", "must be positive")
+	v.check(!l.AvailableFrom.IsZero(), "available_from", "must be set")
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+func (p *Personal) validate(v *validator, field string) {
+	username := strings.TrimSpace(p.Username)
+	v.check(username != "", field+".username", "must not be empty")
+	v.check(utf8.RuneCountInString(username) <= MaxUsernameLength, field+".username",
+		fmt.Sprintf("must be at most %d characters long", MaxUsernameLength))
+	// Any is rejected by App with ErrGenderNotSpecified
+	v.check(p.Gender >= Any && p.Gender <= Female, field+".gender", "unknown gender")
+	v.check(p.Age >= 0 && p.Age <= MaxAge, field+".age", fmt.Sprintf("must be between 0 and %d", MaxAge))
+	p.Lifestyle.validate(v, field+".lifestyle")
+}
+
+func (l *Lifestyle) validate(v *validator, field string) {
+	validateTrait(v, field+".smoking", l.Smoking)
+	validateTrait(v, field+".pets", l.Pets)
+	validateTrait(v, field+".sleep_schedule", l.SleepSchedule)
+	validateTrait(v, field+".cleanliness", l.Cleanliness)
+	validateTrait(v, field+".guests", l.Guests)
+}
+
+func validateTrait[T Trait](v *validator, field string, t T) {
+	v.check(t >= Unspecified && t <= 2, field, "must be 0, 1 or 2")
+}
+
+func (p Preference[T]) validate(v *validator, field string) {
+	validateTrait(v, field+".value", p.Value)
+	v.check(p.Importance >= DontCare && p.Importance <= Must, field+".importance", "must be 0, 1 or 2")
+	v.check(p.Importance == DontCare || p.Value != Unspecified, field+".value", "must be specified if importance is set")
+}
+
+func (c *SearchCriteria) validate(v *validator, field string, regions, stations map[int64]struct{}) {
+	validateIDs(v, field+".regions", "region", c.Regions, regions)
+	validateIDs(v, field+".stations", "station", c.Stations, stations)
+	if c.Location != nil {
+		c.Location.validate(v, field+".location")
+		v.check(c.MaxDistance != nil, field+".max_distance_km", "must be set with location")
+	}
+	if c.MaxDistance != nil {
+		v.check(*c.MaxDistance > 0 && *c.MaxDistance <= MaxDistanceKm, field+".max_distance_km",
+			fmt.Sprintf("must be between 0 and %d", MaxDistanceKm))
+		v.check(c.Location != nil, field+".location", "must be set with max_distance_km")
+	}
+	c.PriceRange.validate(v, field+".price_range", 0)
+	c.AgeRange.validate(v, field+".age_range", MaxAge)
+	v.check(c.Gender == Any || c.Gender == Male || c.Gender == Female, field+".gender", "must be any, male or female")
+	path := field + ".lifestyle."
+	c.Lifestyle.Smoking.validate(v, path+"smoking")
+	c.Lifestyle.Pets.validate(v, path+"pets")
+	c.Lifestyle.SleepSchedule.validate(v, path+"sleep_schedule")
+	c.Lifestyle.Cleanliness.validate(v, path+"cleanliness")
+	c.Lifestyle.Guests.validate(v, path+"guests")
+}
+
+// validateIDs checks that ids are known and not repeated, kind names them in the messages.
+func validateIDs(v *validator, field, kind string, ids []int64, known map[int64]struct{}) {
+	seen := make(map[int64]struct{}, len(ids))
+	for i, id := range ids {
+		_, ok := known[id]
+		v.check(ok, fmt.Sprintf("%s.%d", field, i), fmt.Sprintf("unknown %s %d", kind, id))
+		_, duplicate := seen[id]
+		v.check(!duplicate, fmt.Sprintf("%s.%d", field, i), fmt.Sprintf("duplicate %s %d", kind, id))
+		seen[id] = struct{}{}
+	}
+}
+
+// validate checks that the bounds are not negative, do not exceed limit if it is positive and are ordered.
+func (r *Range) validate(v *validator, field string, limit float64) {
+	bounds := []struct {
+		name  string
+		value *float64
+	}{
+		{"from", r.From},
+		{"to", r.To},
+	}
+	for _, b := range bounds {
+		if b.value == nil {
+			continue
+		}
+		v.check(*b.value >= 0, field+"."+b.name, "must not be negative")
+		v.check(limit <= 0 || *b.value <= limit, field+"."+b.name, fmt.Sprintf("must not exceed %v", limit))
+	}
+	if r.From != nil && r.To != nil {
+		v.check(*r.From <= *r.To, field, "from must not exceed to")
+	}
+}
+
+func (s *Settings) validate(v *validator, field string) {
+	v.check(s.Theme >= 0, field+".theme", "must not be negative")
+	v.check(s.Visibility >= Active && s.Visibility <= Hidden, field+".visibility", "unknown visibility")
+	// reported on the whole settings, so patching either of the fields checks it
+	v.check(s.Visibility != Hidden || s.HiddenUntil != nil, field, "hidden_until must be set for hidden profiles")
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	regions := map[int64]struct{}{1: {}, 2: {}}
+	stations := map[int64]struct{}{101: {}, 102: {}}
+	valid := func() Config {
+		return Config{
+			Personal: &Personal{Username: "chuvak", AvatarLink: "https://example.com/a.jpg", Gender: Male, Age: 26},
+			Criteria: &SearchCriteria{
+				Regions:    []int64{1, 2},
+				Stations:   []int64{101},
+				PriceRange: NewRange(20000, 40000),
+				AgeRange:   NewRange(20, 35),
+			},
+			Settings: &Settings{Theme: 1},
+		}
+	}
+	fields := func(err error) []string {
+		var invalid *ValidationError
+		require.ErrorAs(t, err, &invalid)
+		require.ErrorIs(t, err, common.ErrValidation)
+		var result []string
+		for _, f := range invalid.Fields {
+			result = append(result, f.Field)
+		}
+		return result
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		config := valid()
+		require.NoError(t, config.Validate(regions, stations))
+	})
+	t.Run("personal", func(t *testing.T) {
+		config := valid()
+		config.Personal.Username = " "
+		config.Personal.Age = -1
+		config.Personal.Lifestyle.Pets = 3
+		require.ElementsMatch(t, []string{
+			"personal.username", "personal.age", "personal.lifestyle.pets",
+		}, fields(config.Validate(regions, stations)))
+	})
+	t.Run("criteria", func(t *testing.T) {
+		config := valid()
+		config.Criteria.Regions = []int64{1, 1, 42}
+		config.Criteria.Stations = []int64{102, 999}
+		config.Criteria.PriceRange = NewRange(40000, 20000)
+		config.Criteria.AgeRange = NewRange(-5, 200)
+		config.Criteria.Lifestyle.Smoking = Preference[Smoking]{Importance: Must}
+		require.ElementsMatch(t, []string{
+			"criteria.regions.1", "criteria.regions.2", "criteria.stations.1", "criteria.price_range",
+			"criteria.age_range.from", "criteria.age_range.to", "criteria.lifestyle.smoking.value",
+		}, fields(config.Validate(regions, stations)))
+	})
+	t.Run("location", func(t *testing.T) {
+		config := valid()
+		config.Criteria.Location = &Point{Lat: 55.75, Lon: 37.62}
+		distance := 5.0
+		config.Criteria.MaxDistance = &distance
+		require.NoError(t, config.Validate(regions, stations))
+		config.Criteria.Location = &Point{Lat: 91, Lon: -181}
+		distance = MaxDistanceKm + 1
+		require.ElementsMatch(t, []string{
+			"criteria.location", "criteria.max_distance_km",
+		}, fields(config.Validate(regions, stations)))
+		config.Criteria.Location = nil
+		distance = 5
+		require.Equal(t, []string{"criteria.location"}, fields(config.Validate(regions, stations)))
+		config.Criteria.Location = &Point{Lat: 55.75, Lon: 37.62}
+		config.Criteria.MaxDistance = nil
+		require.Equal(t, []string{"criteria.max_distance_km"}, fields(config.Validate(regions, stations)))
+	})
+	t.Run("settings", func(t *testing.T) {
+		config := valid()
+		config.Settings.Theme = -1
+		require.Equal(t, []string{"settings.theme"}, fields(config.Validate(regions, stations)))
+		config = valid()
+		config.Settings.Visibility = 3
+		require.Equal(t, []string{"settings.visibility"}, fields(config.Validate(regions, stations)))
+		config.Settings.Visibility = Hidden
+		require.Equal(t, []string{"settings"}, fields(config.ValidatePatch(regions, stations, FieldMask{"settings.visibility": {}})))
+		until := time.Now().Add(time.Hour)
+		config.Settings.HiddenUntil = &until
+		require.NoError(t, config.Validate(regions, stations))
+	})
+	t.Run("patch checks only touched fields", func(t *testing.T) {
+		config := valid()
+		config.Personal.Username = ""
+		config.Criteria.PriceRange = NewRange(40000, 20000)
+		mask := FieldMask{"criteria.price_range.to": {}}
+		require.Equal(t, []string{"criteria.price_range"}, fields(config.ValidatePatch(regions, stations, mask)))
+		require.NoError(t, config.ValidatePatch(regions, stations, FieldMask{"settings.theme": {}}))
+		require.Equal(t, []string{"role"}, fields(config.ValidatePatch(regions, stations, FieldMask{"role": {}})))
+		config.Role = RoleOf(ListingOwner)
+		require.NoError(t, config.ValidatePatch(regions, stations, FieldMask{"role": {}}))
+	})
+}
+
+func TestListingValidate(t *testing.T) {
+	regions := map[int64]struct{}{3: {}}
+	listing := Listing{RegionID: 3, Rent: 30000, Rooms: 1, AvailableFrom: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	require.NoError(t, listing.Validate(regions))
+
+	listing = Listing{RegionID: 4, Location: &Point{Lat: 91, Lon: 37.62}, Rent: -1}
+	err := listing.Validate(regions)
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.ErrorIs(t, err, common.ErrValidation)
+	var fields []string
+	for _, f := range invalid.Fields {
+		fields = append(fields, f.Field)
+	}
+	require.Equal(t, []string{"region_id", "location", "rent", "rooms", "available_from"}, fields)
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+)
+
+// Block hides uuid and target from each other and closes their dialog.
+func (a *App) Block(ctx context.Context, uuid, target string) error {
+	if uuid == target {
+		return common.ErrSelfAction
+	}
+	err := a.store.Block(ctx, uuid, target)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrConfigNotFound
+	default:
+		return fmt.Errorf("err blocking user: %w", err)
+	}
+	a.chatServer.CloseDialog(uuid, target)
+	return nil
+}
+
+// Report puts a complaint about report.Target into the moderation queue.
+func (a *App) Report(ctx context.Context, report *models.Report) error {
+	if report.UUID == report.Target {
+		return common.ErrSelfAction
+	}
+	if !report.Reason.Valid() {
+		return common.ErrInvalidReportReason
+	}
+	err := a.store.Report(ctx, report)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrConfigNotFound
+	default:
+		return fmt.Errorf("err reporting user: %w", err)
+	}
+	return nil
+}
+
+// ListReports returns the moderation queue to actor, the oldest reports first. Reports disclose who
+// reported whom, so the listing is audited with the ids of the shown reports.
+func (a *App) ListReports(ctx context.Context, actor string, limit, offset int64) ([]*models.Report, error) {
+	reports, err := a.store.ListReports(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("err listing reports: %w", err)
+	}
+	ids := make([]int64, 0, len(reports))
+	for _, report := range reports {
+		ids = append(ids, report.ID)
+	}
+	details, err := json.Marshal(struct {
+		IDs []int64 `json:"ids"`
+	}{ids})
+	if err != nil {
+		return nil, fmt.Errorf("err marshaling report ids: %w", err)
+	}
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditListReports, Details: details}
+	if err = a.store.Audit(ctx, &entry); err != nil {
+		return nil, fmt.Errorf("err listing reports: %w", err)
+	}
+	return reports, nil
+}
+
+// ResolveReport takes the report out of the moderation queue on behalf of actor.
+func (a *App) ResolveReport(ctx context.Context, actor string, id int64, resolution models.Resolution, note string) (*models.Report, error) { //nolint:lll
+	if resolution != models.Dismissed && resolution != models.ActionTaken {
+		return nil, common.ErrInvalidResolution
+	}
+	details, err := json.Marshal(struct {
+		Resolution models.Resolution `json:"resolution"`
+		Note       string            `json:"note"`
+	}{resolution, note})
+	if err != nil {
+		return nil, fmt.Errorf("err marshaling resolution: %w", err)
+	}
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditResolveReport, Details: details}
+	report, err := a.store.ResolveReport(ctx, id, resolution, note, &entry)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrReportNotFound):
+		return nil, common.ErrReportNotFound
+	default:
+		return nil, fmt.Errorf("err resolving report: %w", err)
+	}
+	return report, nil
+}
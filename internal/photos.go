@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // register png decoder
+	"io"
+	"net/http"
+
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/blob"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/google/uuid"
+)
+
+var photoExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+}
+
+// AddPhoto checks the uploaded image, stores it with a thumbnail and appends it to the gallery of uuid.
+func (a *App) AddPhoto(ctx context.Context, uuid string, r io.Reader) (*models.Photo, error) {
+	data, err := io.ReadAll(io.LimitReader(r, models.MaxPhotoSize+1))
+	if err != nil {
+		return nil, common.WithDetail(common.ErrBadRequest, "reading photo: "+err.Error())
+	}
+	if len(data) > models.MaxPhotoSize {
+		return nil, common.ErrPhotoTooLarge
+	}
+	ext, ok := photoExtensions[http.DetectContentType(data)]
+	if !ok {
+		return nil, common.ErrUnsupportedPhoto
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, common.ErrUnsupportedPhoto
+	}
+	if cfg.Width*cfg.Height > models.MaxPhotoPixels {
+		return nil, common.ErrPhotoTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, common.ErrUnsupportedPhoto
+	}
+	var thumb bytes.Buffer
+	if err = jpeg.Encode(&thumb, thumbnail(img, models.ThumbnailSize), nil); err != nil {
+		return nil, fmt.Errorf("err encoding thumbnail: %w", err)
+	}
+
+	// keys end up in URLs, so they must not reveal the owner
+	name := newPhotoName()
+	photo := models.Photo{
+		UUID:         uuid,
+		Key:          fmt.Sprintf("%s.%s", name, ext),
+		ThumbnailKey: fmt.Sprintf("%s_thumb.jpg", name),
+	}
+	photo.URL = a.blobs.URL(photo.Key)
+	photo.ThumbnailURL = a.blobs.URL(photo.ThumbnailKey)
+	if err = a.blobs.Put(ctx, photo.Key, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("err storing photo: %w", err)
+	}
+	if err = a.blobs.Put(ctx, photo.ThumbnailKey, &thumb); err != nil {
+		a.deletePhotoFiles(ctx, &photo)
+		return nil, fmt.Errorf("err storing thumbnail: %w", err)
+	}
+	err = a.store.AddPhoto(ctx, &photo, a.maxPhotos)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrTooManyPhotos), errors.Is(err, common.ErrConfigNotFound):
+		a.deletePhotoFiles(ctx, &photo)
+		return nil, err
+	default:
+		a.deletePhotoFiles(ctx, &photo)
+		return nil, fmt.Errorf("err adding photo: %w", err)
+	}
+	return &photo, nil
+}
+
+func (a *App) ListPhotos(ctx context.Context, uuid string) ([]*models.Photo, error) {
+	photos, err := a.store.ListPhotos(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("err listing photos: %w", err)
+	}
+	return photos, nil
+}
+
+func (a *App) DeletePhoto(ctx context.Context, uuid string, id int64) error {
+	photo, err := a.store.DeletePhoto(ctx, uuid, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrPhotoNotFound), errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrPhotoNotFound
+	default:
+		return fmt.Errorf("err deleting photo: %w", err)
+	}
+	a.deletePhotoFiles(ctx, photo)
+	return nil
+}
+
+// ReorderPhotos sets the order of the gallery, the first photo becomes the avatar.
+func (a *App) ReorderPhotos(ctx context.Context, uuid string, ids []int64) ([]*models.Photo, error) {
+	err := a.store.ReorderPhotos(ctx, uuid, ids)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrInvalidPhotoOrder), errors.Is(err, common.ErrConfigNotFound):
+		return nil, common.ErrInvalidPhotoOrder
+	default:
+		return nil, fmt.Errorf("err reordering photos: %w", err)
+	}
+	return a.ListPhotos(ctx, uuid)
+}
+
+// OpenPhoto returns the content of a stored photo or thumbnail if viewer may see it: photos of hidden,
+// deleted and blocked or blocking users are not found.
+func (a *App) OpenPhoto(ctx context.Context, viewer, key string) (io.ReadCloser, error) {
+	visible, err := a.store.PhotoVisible(ctx, viewer, key)
+	if err != nil {
+		return nil, fmt.Errorf("err opening photo: %w", err)
+	}
+	if !visible {
+		return nil, common.ErrPhotoNotFound
+	}
+	r, err := a.blobs.Get(ctx, key)
+	switch {
+	case err == nil:
+	case errors.Is(err, blob.ErrNotFound), errors.Is(err, blob.ErrInvalidKey):
+		return nil, common.ErrPhotoNotFound
+	default:
+		return nil, fmt.Errorf("err opening photo: %w", err)
+	}
+	return r, nil
+}
+
+// deletePhotoFiles removes the files of the photo, failures only leave garbage behind, so they are just logged.
+func (a *App) deletePhotoFiles(ctx context.Context, photo *models.Photo) {
+	for _, key := range []string{photo.Key, photo.ThumbnailKey} {
+		if err := a.blobs.Delete(ctx, key); err != nil {
+			a.log.Warnf("err deleting photo file %s: %v", key, err)
+		}
+	}
+}
+
+func newPhotoName() string {
+	return uuid.NewString()
+}
+
+// thumbnail scales img down to fit into a size x size square, averaging the pixels that fall into each
+// pixel of the result. Images that already fit are only copied.
+func thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, h*size/w
+		} else {
+			tw, th = w*size/h, size
+		}
+	}
+	if tw == 0 {
+		tw = 1
+	}
+	if th == 0 {
+		th = 1
+	}
+	at := pixelReader(img)
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*h/th, bounds.Min.Y+(y+1)*h/th
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*w/tw, bounds.Min.X+(x+1)*w/tw
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := at(sx, sy)
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// pixelReader returns the premultiplied RGBA of the pixels of img. The images jpeg and png decode to are
+// read directly, as img.At allocates a color for every pixel.
+func pixelReader(img image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch src := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) (r, g, b, a uint32) {
+			return src.YCbCrAt(x, y).RGBA()
+		}
+	case *image.RGBA:
+		return func(x, y int) (r, g, b, a uint32) {
+			return src.RGBAAt(x, y).RGBA()
+		}
+	case *image.NRGBA:
+		return func(x, y int) (r, g, b, a uint32) {
+			return src.NRGBAAt(x, y).RGBA()
+		}
+	case *image.Gray:
+		return func(x, y int) (r, g, b, a uint32) {
+			return src.GrayAt(x, y).RGBA()
+		}
+	default:
+		return func(x, y int) (r, g, b, a uint32) {
+			return img.At(x, y).RGBA()
+		}
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path"
 	"strconv"
+	"time"
 
 	"github.com/gerladeno/homie-core/pkg/chat"
 
@@ -14,6 +19,7 @@ import (
 
 	"github.com/gerladeno/homie-core/internal/models"
 	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/gerladeno/homie-core/pkg/metrics"
 
 	"github.com/sirupsen/logrus"
 )
@@ -41,39 +47,73 @@ func (h *handler) saveConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	var config models.Config
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		writeErrResponse(w, fmt.Sprintf("%s: %v", http.StatusText(http.StatusBadRequest), err), http.StatusBadRequest)
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
 		return
 	}
 	config.SetUUID(uuid)
-	err := h.service.SaveConfig(r.Context(), &config)
-	switch {
-	case err == nil:
-	case errors.Is(err, common.ErrGenderNotSpecified):
-		writeErrResponse(w, fmt.Sprintf("%s: %v", http.StatusText(http.StatusBadRequest), err), http.StatusBadRequest)
+	if err := h.service.SaveConfig(r.Context(), &config); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err saving config: %w", err))
 		return
-	default:
-		h.log.Warnf("err saving config: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+	writeResponse(w, "Ok")
+}
+
+func (h *handler) patchConfig(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	config, err := h.service.PatchConfig(r.Context(), uuid, patch)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err patching config: %w", err))
+		return
+	}
+	writeResponse(w, config)
+}
+
+func (h *handler) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteAccount(r.Context(), uuid); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err deleting account: %w", err))
 		return
 	}
 	writeResponse(w, "Ok")
 }
 
-func (h *handler) getConfig(w http.ResponseWriter, r *http.Request) {
+// export streams the archive of the user data, errors after the first byte is written can only be logged.
+func (h *handler) export(w http.ResponseWriter, r *http.Request) {
 	uuid, ok := h.getUUID(w, r)
 	if !ok {
 		return
 	}
 	config, err := h.service.GetConfig(r.Context(), uuid)
-	switch {
-	case err == nil:
-	case errors.Is(err, common.ErrConfigNotFound):
-		writeErrResponse(w, http.StatusText(http.StatusNoContent), http.StatusNoContent)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err getting config: %w", err))
 		return
 	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="homie-export.zip"`)
+	if err = h.service.Export(r.Context(), config, w); err != nil {
+		h.log.Warnf("err exporting data of %s: %v", uuid, err)
+	}
+}
+
+func (h *handler) getConfig(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	config, err := h.service.GetConfig(r.Context(), uuid)
 	if err != nil {
-		h.log.Warnf("err getting config: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err getting config: %w", err))
 		return
 	}
 	writeResponse(w, config)
@@ -82,47 +122,49 @@ func (h *handler) getConfig(w http.ResponseWriter, r *http.Request) {
 func (h *handler) getMatches(w http.ResponseWriter, r *http.Request) {
 	val := r.URL.Query().Get("count")
 	count, _ := strconv.ParseInt(val, 10, 64)
+	if count <= 0 {
+		count = defaultLimit
+	}
 	uuid, ok := h.getUUID(w, r)
 	if !ok {
 		return
 	}
-	result, err := h.service.GetMatches(r.Context(), uuid, count)
+	result, page, err := h.service.GetMatches(r.Context(), uuid, count, r.URL.Query().Get("cursor"))
 	if err != nil {
-		h.log.Warnf("err getting config: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err getting matches: %w", err))
 		return
 	}
-	writeResponse(w, result)
+	writePageResponse(w, result, page)
 }
 
 func (h *handler) like(w http.ResponseWriter, r *http.Request) {
 	val := r.URL.Query().Get("super")
 	super, err := strconv.ParseBool(val)
 	if err != nil {
-		writeErrResponse(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.writeErr(w, r, common.ErrBadRequest)
 		return
 	}
 	targetUUID := chi.URLParam(r, "uuid")
 	if !common.IsValidUUID(targetUUID) {
-		writeErrResponse(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.writeErr(w, r, common.ErrInvalidUUID)
 		return
 	}
 	uuid, ok := h.getUUID(w, r)
 	if !ok {
 		return
 	}
-	if err = h.service.Like(r.Context(), uuid, targetUUID, super); err != nil {
-		h.log.Warnf("err liking: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	matched, err := h.service.Like(r.Context(), uuid, targetUUID, super)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err liking: %w", err))
 		return
 	}
-	writeResponse(w, "Ok")
+	writeResponse(w, models.LikeResult{Match: matched})
 }
 
 func (h *handler) dislike(w http.ResponseWriter, r *http.Request) {
 	targetUUID := chi.URLParam(r, "uuid")
 	if !common.IsValidUUID(targetUUID) {
-		writeErrResponse(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.writeErr(w, r, common.ErrInvalidUUID)
 		return
 	}
 	uuid, ok := h.getUUID(w, r)
@@ -130,38 +172,289 @@ func (h *handler) dislike(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := h.service.Dislike(r.Context(), uuid, targetUUID); err != nil {
-		h.log.Warnf("err disliking: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err disliking: %w", err))
+		return
+	}
+	writeResponse(w, "Ok")
+}
+
+func (h *handler) block(w http.ResponseWriter, r *http.Request) {
+	targetUUID := chi.URLParam(r, "uuid")
+	if !common.IsValidUUID(targetUUID) {
+		h.writeErr(w, r, common.ErrInvalidUUID)
+		return
+	}
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.Block(r.Context(), uuid, targetUUID); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err blocking: %w", err))
+		return
+	}
+	writeResponse(w, "Ok")
+}
+
+func (h *handler) report(w http.ResponseWriter, r *http.Request) {
+	targetUUID := chi.URLParam(r, "uuid")
+	if !common.IsValidUUID(targetUUID) {
+		h.writeErr(w, r, common.ErrInvalidUUID)
+		return
+	}
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	var report models.Report
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	report.UUID, report.Target = uuid, targetUUID
+	if err := h.service.Report(r.Context(), &report); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err reporting: %w", err))
+		return
+	}
+	writeResponse(w, report)
+}
+
+func (h *handler) listReports(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset := h.limitOffset(w, r)
+	reports, err := h.service.ListReports(r.Context(), actor, limit, offset)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing reports: %w", err))
+		return
+	}
+	writeResponse(w, reports)
+}
+
+func (h *handler) resolveReport(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeErr(w, r, common.ErrBadRequest)
+		return
+	}
+	var resolution struct {
+		Resolution models.Resolution `json:"resolution"`
+		Note       string            `json:"note"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&resolution); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	report, err := h.service.ResolveReport(r.Context(), actor, id, resolution.Resolution, resolution.Note)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err resolving report: %w", err))
+		return
+	}
+	writeResponse(w, report)
+}
+
+func (h *handler) lookupConfig(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	targetUUID := chi.URLParam(r, "uuid")
+	if !common.IsValidUUID(targetUUID) {
+		h.writeErr(w, r, common.ErrInvalidUUID)
+		return
+	}
+	config, err := h.service.LookupConfig(r.Context(), actor, targetUUID)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err looking up config: %w", err))
+		return
+	}
+	writeResponse(w, config)
+}
+
+func (h *handler) hideProfile(w http.ResponseWriter, r *http.Request) {
+	h.setProfileHidden(w, r, true)
+}
+
+func (h *handler) unhideProfile(w http.ResponseWriter, r *http.Request) {
+	h.setProfileHidden(w, r, false)
+}
+
+func (h *handler) setProfileHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	targetUUID := chi.URLParam(r, "uuid")
+	if !common.IsValidUUID(targetUUID) {
+		h.writeErr(w, r, common.ErrInvalidUUID)
+		return
+	}
+	if err := h.service.SetProfileHidden(r.Context(), actor, targetUUID, hidden); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err hiding profile: %w", err))
+		return
+	}
+	writeResponse(w, "Ok")
+}
+
+func (h *handler) listAllRegions(w http.ResponseWriter, r *http.Request) {
+	regions, err := h.service.ListAllRegions(r.Context())
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing regions: %w", err))
+		return
+	}
+	writeResponse(w, regions)
+}
+
+func (h *handler) getRegion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeErr(w, r, common.ErrBadRequest)
+		return
+	}
+	region, err := h.service.GetRegion(r.Context(), id)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err getting region: %w", err))
+		return
+	}
+	writeResponse(w, region)
+}
+
+func (h *handler) createRegion(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	var region models.Region
+	if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	if err := h.service.CreateRegion(r.Context(), actor, &region); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err creating region: %w", err))
+		return
+	}
+	writeResponse(w, region)
+}
+
+func (h *handler) updateRegion(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeErr(w, r, common.ErrBadRequest)
+		return
+	}
+	var region models.Region
+	if err = json.NewDecoder(r.Body).Decode(&region); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	region.ID = id
+	if err = h.service.UpdateRegion(r.Context(), actor, &region); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err updating region: %w", err))
+		return
+	}
+	writeResponse(w, region)
+}
+
+func (h *handler) deleteRegion(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeErr(w, r, common.ErrBadRequest)
+		return
+	}
+	if err = h.service.DeleteRegion(r.Context(), actor, id); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err deleting region: %w", err))
 		return
 	}
 	writeResponse(w, "Ok")
 }
 
+func (h *handler) listAudit(w http.ResponseWriter, r *http.Request) {
+	limit, offset := h.limitOffset(w, r)
+	entries, err := h.service.ListAudit(r.Context(), limit, offset)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing audit log: %w", err))
+		return
+	}
+	writeResponse(w, entries)
+}
+
+func (h *handler) undo(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	relation, err := h.service.Undo(r.Context(), uuid)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err undoing: %w", err))
+		return
+	}
+	writeResponse(w, relation)
+}
+
 func (h *handler) listLiked(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	limit, cursor := h.limitCursor(r)
+	result, page, err := h.service.ListLikedProfiles(r.Context(), uuid, limit, cursor)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing liked: %w", err))
+		return
+	}
+	writePageResponse(w, result, page)
+}
+
+func (h *handler) listDisliked(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	limit, cursor := h.limitCursor(r)
+	result, page, err := h.service.ListDislikedProfiles(r.Context(), uuid, limit, cursor)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing disliked: %w", err))
+		return
+	}
+	writePageResponse(w, result, page)
+}
+
+func (h *handler) listAdmirers(w http.ResponseWriter, r *http.Request) {
 	uuid, ok := h.getUUID(w, r)
 	if !ok {
 		return
 	}
 	limit, offset := h.limitOffset(w, r)
-	result, err := h.service.ListLikedProfiles(r.Context(), uuid, limit, offset)
+	result, err := h.service.ListAdmirers(r.Context(), uuid, limit, offset)
 	if err != nil {
-		h.log.Warnf("err listing liked: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err listing admirers: %w", err))
 		return
 	}
 	writeResponse(w, result)
 }
 
-func (h *handler) listDisliked(w http.ResponseWriter, r *http.Request) {
+func (h *handler) listMutual(w http.ResponseWriter, r *http.Request) {
 	uuid, ok := h.getUUID(w, r)
 	if !ok {
 		return
 	}
 	limit, offset := h.limitOffset(w, r)
-	result, err := h.service.ListDislikedProfiles(r.Context(), uuid, limit, offset)
+	result, err := h.service.ListMutualMatches(r.Context(), uuid, limit, offset)
 	if err != nil {
-		h.log.Warnf("err listing disliked: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err listing mutual matches: %w", err))
 		return
 	}
 	writeResponse(w, result)
@@ -172,13 +465,13 @@ func (h *handler) getAllChats(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	profiles, err := h.service.GetAllChats(r.Context(), uuid)
+	limit, cursor := h.limitCursor(r)
+	profiles, page, err := h.service.GetAllChats(r.Context(), uuid, limit, cursor)
 	if err != nil {
-		h.log.Warnf("err getting all chats: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err getting all chats: %w", err))
 		return
 	}
-	writeResponse(w, profiles)
+	writePageResponse(w, profiles, page)
 }
 
 func (h *handler) chatHandler(w http.ResponseWriter, r *http.Request) {
@@ -188,22 +481,284 @@ func (h *handler) chatHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	targetUUID := chi.URLParam(r, "uuid")
 	if !common.IsValidUUID(targetUUID) {
-		writeErrResponse(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.writeErr(w, r, common.ErrInvalidUUID)
+		return
+	}
+	hub, err := h.service.GetDialog(r.Context(), uuid, targetUUID)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err getting dialog: %w", err))
+		return
+	}
+	chat.WebsocketChatHandler(hub, uuid, w, r)
+}
+
+func (h *handler) getMessages(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	targetUUID := chi.URLParam(r, "uuid")
+	if !common.IsValidUUID(targetUUID) {
+		h.writeErr(w, r, common.ErrInvalidUUID)
+		return
+	}
+	before := time.Now()
+	if val := r.URL.Query().Get("before"); val != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339Nano, val); err != nil {
+			h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+			return
+		}
+	}
+	var beforeID int64
+	if val := r.URL.Query().Get("before_id"); val != "" {
+		var err error
+		if beforeID, err = strconv.ParseInt(val, 10, 64); err != nil {
+			h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+			return
+		}
+	}
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	messages, err := h.service.GetMessages(r.Context(), uuid, targetUUID, before, beforeID, limit)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err getting messages: %w", err))
+		return
+	}
+	writeResponse(w, messages)
+}
+
+func (h *handler) listListings(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	listings, err := h.service.ListListings(r.Context(), uuid)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing listings: %w", err))
+		return
+	}
+	writeResponse(w, listings)
+}
+
+func (h *handler) createListing(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	var listing models.Listing
+	if err := json.NewDecoder(r.Body).Decode(&listing); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	listing.UUID = uuid
+	if err := h.service.CreateListing(r.Context(), &listing); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err creating listing: %w", err))
+		return
+	}
+	writeResponse(w, listing)
+}
+
+func (h *handler) getListing(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.getListingID(w, r)
+	if !ok {
+		return
+	}
+	listing, err := h.service.GetListing(r.Context(), id)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err getting listing: %w", err))
+		return
+	}
+	writeResponse(w, listing)
+}
+
+func (h *handler) updateListing(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := h.getListingID(w, r)
+	if !ok {
+		return
+	}
+	var listing models.Listing
+	if err := json.NewDecoder(r.Body).Decode(&listing); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	listing.ID = id
+	listing.UUID = uuid
+	if err := h.service.UpdateListing(r.Context(), &listing); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err updating listing: %w", err))
+		return
+	}
+	writeResponse(w, listing)
+}
+
+func (h *handler) deleteListing(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := h.getListingID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteListing(r.Context(), uuid, id); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err deleting listing: %w", err))
+		return
+	}
+	writeResponse(w, "Ok")
+}
+
+// maxPhotoRequestSize leaves room for multipart headers and other form fields.
+const maxPhotoRequestSize = models.MaxPhotoSize + 1<<20
+
+func (h *handler) uploadPhoto(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoRequestSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	var part *multipart.Part
+	for {
+		if part, err = reader.NextPart(); err != nil {
+			h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, "no photo field: "+err.Error()))
+			return
+		}
+		if part.FormName() == "photo" {
+			break
+		}
+	}
+	photo, err := h.service.AddPhoto(r.Context(), uuid, part)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err adding photo: %w", err))
+		return
+	}
+	writeResponse(w, photo)
+}
+
+func (h *handler) listPhotos(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	photos, err := h.service.ListPhotos(r.Context(), uuid)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err listing photos: %w", err))
+		return
+	}
+	writeResponse(w, photos)
+}
+
+func (h *handler) reorderPhotos(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	var order struct {
+		Order []int64 `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		h.writeErr(w, r, common.WithDetail(common.ErrBadRequest, err.Error()))
+		return
+	}
+	photos, err := h.service.ReorderPhotos(r.Context(), uuid, order.Order)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err reordering photos: %w", err))
+		return
+	}
+	writeResponse(w, photos)
+}
+
+func (h *handler) deletePhoto(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeErr(w, r, common.ErrBadRequest)
+		return
+	}
+	if err = h.service.DeletePhoto(r.Context(), uuid, id); err != nil {
+		h.writeErr(w, r, fmt.Errorf("err deleting photo: %w", err))
 		return
 	}
-	hub := h.service.GetDialog(r.Context(), uuid, targetUUID)
-	chat.WebsocketChatHandler(hub, w, r)
+	writeResponse(w, "Ok")
+}
+
+func (h *handler) getPhotoFile(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := h.getUUID(w, r)
+	if !ok {
+		return
+	}
+	key := chi.URLParam(r, "*")
+	file, err := h.service.OpenPhoto(r.Context(), uuid, key)
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err opening photo: %w", err))
+		return
+	}
+	defer func() {
+		if err = file.Close(); err != nil {
+			h.log.Warnf("err closing photo %s: %v", key, err)
+		}
+	}()
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	// keys are never reused, but who may see a photo changes, so only the client caches it and not for long
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if _, err = io.Copy(w, file); err != nil {
+		h.log.Debugf("err sending photo %s: %v", key, err)
+	}
+}
+
+// writeErr reports err to the client. Errors from pkg/common are written with their code and status,
+// the rest are logged and reported as internal ones. The code also goes to the response metrics.
+func (h *handler) writeErr(w http.ResponseWriter, r *http.Request, err error) {
+	var domainErr *common.Error
+	if !errors.As(err, &domainErr) {
+		h.log.Warn(err)
+		domainErr = common.ErrInternal
+	}
+	metrics.SetErrDescription(r.Context(), domainErr.Code)
+	var detailed *common.Detailed
+	if errors.As(err, &detailed) && detailed.Err == domainErr {
+		withDetail := *domainErr
+		withDetail.Message = detailed.Error()
+		domainErr = &withDetail
+	}
+	var fields []models.FieldError
+	var invalid *models.ValidationError
+	if errors.As(err, &invalid) {
+		fields = invalid.Fields
+	}
+	writeErrResponse(w, domainErr, fields...)
+}
+
+func (h *handler) getListingID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.writeErr(w, r, common.ErrBadRequest)
+		return 0, false
+	}
+	return id, true
 }
 
 func (h *handler) getUUID(w http.ResponseWriter, r *http.Request) (string, bool) {
 	uuid, ok := r.Context().Value(uuidKey).(string)
 	if !ok {
-		h.log.Warnf("err invalid context value")
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, errors.New("err invalid context value"))
 		return "", false
 	}
 	if !common.IsValidUUID(uuid) {
-		writeErrResponse(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.writeErr(w, r, common.ErrInvalidUUID)
 		return "", false
 	}
 	return uuid, ok
@@ -212,18 +767,37 @@ func (h *handler) getUUID(w http.ResponseWriter, r *http.Request) (string, bool)
 func (h *handler) limitOffset(w http.ResponseWriter, r *http.Request) (int64, int64) {
 	val := r.URL.Query().Get("limit")
 	limit, _ := strconv.ParseInt(val, 10, 64)
-	if limit == 0 {
+	if limit <= 0 {
 		limit = defaultLimit
 	}
 	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if offset < 0 {
+		offset = 0
+	}
 	return limit, offset
 }
 
+func (h *handler) limitCursor(r *http.Request) (int64, string) {
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	return limit, r.URL.Query().Get("cursor")
+}
+
 func (h *handler) getRegions(w http.ResponseWriter, r *http.Request) {
 	result, err := h.service.GetRegions(r.Context())
 	if err != nil {
-		h.log.Warnf("err getting regions: %v", err)
-		writeErrResponse(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.writeErr(w, r, fmt.Errorf("err getting regions: %w", err))
+		return
+	}
+	writeResponse(w, result)
+}
+
+func (h *handler) getMetro(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.GetMetro(r.Context())
+	if err != nil {
+		h.writeErr(w, r, fmt.Errorf("err getting metro: %w", err))
 		return
 	}
 	writeResponse(w, result)
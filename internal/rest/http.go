@@ -5,12 +5,14 @@ import (
 	"context"
 	"crypto/rsa"
 	"encoding/json"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gerladeno/homie-core/pkg/chat"
 
 	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
 	"github.com/gerladeno/homie-core/pkg/metrics"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -20,18 +22,51 @@ import (
 
 type Service interface {
 	SaveConfig(ctx context.Context, config *models.Config) error
+	PatchConfig(ctx context.Context, uuid string, patch []byte) (*models.Config, error)
+	DeleteAccount(ctx context.Context, uuid string) error
 	GetConfig(ctx context.Context, uuid string) (*models.Config, error)
+	Export(ctx context.Context, config *models.Config, w io.Writer) error
 	GetRegions(ctx context.Context) ([]*models.Region, error)
-	Like(ctx context.Context, uuid, targetUUID string, super bool) error
+	GetMetro(ctx context.Context) ([]*models.MetroLine, error)
+	Like(ctx context.Context, uuid, targetUUID string, super bool) (bool, error)
 	Dislike(ctx context.Context, uuid, targetUUID string) error
-	ListLikedProfiles(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error)
-	ListDislikedProfiles(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error)
-	GetMatches(ctx context.Context, uuid string, count int64) ([]*models.Profile, error)
-	GetDialog(ctx context.Context, client, target string) *chat.Hub
-	GetAllChats(ctx context.Context, uuid string) ([]*models.Profile, error)
+	Undo(ctx context.Context, uuid string) (*models.Relation, error)
+	ListLikedProfiles(ctx context.Context, uuid string, limit int64, cursor string) ([]*models.Profile, *models.Page, error)
+	ListDislikedProfiles(ctx context.Context, uuid string, limit int64, cursor string) ([]*models.Profile, *models.Page, error)
+	ListAdmirers(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error)
+	ListMutualMatches(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error)
+	GetMatches(ctx context.Context, uuid string, count int64, cursor string) ([]*models.Profile, *models.Page, error)
+	GetDialog(ctx context.Context, client, target string) (*chat.Hub, error)
+	GetAllChats(ctx context.Context, uuid string, limit int64, cursor string) ([]*models.Profile, *models.Page, error)
+	GetMessages(ctx context.Context, uuid, targetUUID string, before time.Time, beforeID, limit int64) ([]*chat.Message, error) //nolint:lll
+	CreateListing(ctx context.Context, listing *models.Listing) error
+	UpdateListing(ctx context.Context, listing *models.Listing) error
+	DeleteListing(ctx context.Context, uuid string, id int64) error
+	GetListing(ctx context.Context, id int64) (*models.Listing, error)
+	ListListings(ctx context.Context, uuid string) ([]*models.Listing, error)
+	AddPhoto(ctx context.Context, uuid string, r io.Reader) (*models.Photo, error)
+	ListPhotos(ctx context.Context, uuid string) ([]*models.Photo, error)
+	DeletePhoto(ctx context.Context, uuid string, id int64) error
+	ReorderPhotos(ctx context.Context, uuid string, ids []int64) ([]*models.Photo, error)
+	OpenPhoto(ctx context.Context, viewer, key string) (io.ReadCloser, error)
+	Block(ctx context.Context, uuid, target string) error
+	Report(ctx context.Context, report *models.Report) error
+	ListReports(ctx context.Context, actor string, limit, offset int64) ([]*models.Report, error)
+	ResolveReport(ctx context.Context, actor string, id int64, resolution models.Resolution, note string) (*models.Report, error) //nolint:lll
+	LookupConfig(ctx context.Context, actor, uuid string) (*models.Config, error)
+	SetProfileHidden(ctx context.Context, actor, uuid string, hidden bool) error
+	CreateRegion(ctx context.Context, actor string, region *models.Region) error
+	UpdateRegion(ctx context.Context, actor string, region *models.Region) error
+	DeleteRegion(ctx context.Context, actor string, id int64) error
+	GetRegion(ctx context.Context, id int64) (*models.Region, error)
+	ListAllRegions(ctx context.Context) ([]*models.Region, error)
+	ListAudit(ctx context.Context, limit, offset int64) ([]*models.AuditEntry, error)
 }
 
-const gitURL = "https://github.com/gerladeno/homie-core"
+const (
+	gitURL         = "https://github.com/gerladeno/homie-core"
+	requestTimeout = 30 * time.Second
+)
 
 func NewRouter(log *logrus.Logger, service Service, key *rsa.PublicKey, host, version string) chi.Router {
 	handler := newHandler(log, service, key)
@@ -48,28 +83,81 @@ func NewRouter(log *logrus.Logger, service Service, key *rsa.PublicKey, host, ve
 	r.Group(func(r chi.Router) {
 		r.Use(metrics.NewPromMiddleware(host))
 		r.Use(middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: log, NoColor: true}))
-		r.Use(middleware.Timeout(30 * time.Second))
 		r.Use(middleware.Throttle(100))
 		r.Route("/static", func(r chi.Router) {
+			r.Use(middleware.Timeout(requestTimeout))
 			r.Get("/regions", handler.getRegions)
+			r.Get("/metro", handler.getMetro)
 		})
 		r.Route("/public", func(r chi.Router) {
 			r.Use(handler.jwtAuth)
 			r.Route("/v1", func(r chi.Router) {
+				// the archive is streamed for as long as it takes, cancelling it midway would leave a broken zip
+				r.Get("/export", handler.export)
 				r.Group(func(r chi.Router) {
+					r.Use(middleware.Timeout(requestTimeout))
 					r.Get("/config", handler.getConfig)
 					r.Put("/config", handler.saveConfig)
+					r.Patch("/config", handler.patchConfig)
+					r.Delete("/config", handler.deleteAccount)
 					r.Get("/matches", handler.getMatches)
 					r.Get("/like/{uuid}", handler.like)
 					r.Get("/dislike/{uuid}", handler.dislike)
+					r.Post("/undo", handler.undo)
+					r.Post("/block/{uuid}", handler.block)
+					r.Post("/report/{uuid}", handler.report)
 					r.Get("/liked", handler.listLiked)
 					r.Get("/disliked", handler.listDisliked)
+					r.Get("/admirers", handler.listAdmirers)
+					r.Get("/mutual", handler.listMutual)
 					r.Get("/chats", handler.getAllChats)
 					r.HandleFunc("/chat/{uuid}", handler.chatHandler)
+					r.Get("/chat/{uuid}/messages", handler.getMessages)
+					r.Route("/listings", func(r chi.Router) {
+						r.Get("/", handler.listListings)
+						r.Post("/", handler.createListing)
+						r.Get("/{id}", handler.getListing)
+						r.Put("/{id}", handler.updateListing)
+						r.Delete("/{id}", handler.deleteListing)
+					})
+					r.Route("/photos", func(r chi.Router) {
+						r.Get("/", handler.listPhotos)
+						r.Post("/", handler.uploadPhoto)
+						r.Put("/", handler.reorderPhotos)
+						r.Delete("/{id}", handler.deletePhoto)
+						r.Get("/files/*", handler.getPhotoFile)
+					})
 				})
 			})
 		})
 		r.Route("/private", func(r chi.Router) {
+			r.Use(middleware.Timeout(requestTimeout))
+			r.Use(handler.jwtAuth)
+			r.Route("/v1", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(handler.requireScope(ScopeReports))
+					r.Get("/reports", handler.listReports)
+					r.Post("/reports/{id}/resolve", handler.resolveReport)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(handler.requireScope(ScopeUsers))
+					r.Get("/configs/{uuid}", handler.lookupConfig)
+					r.Post("/configs/{uuid}/hide", handler.hideProfile)
+					r.Delete("/configs/{uuid}/hide", handler.unhideProfile)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(handler.requireScope(ScopeRegions))
+					r.Get("/regions", handler.listAllRegions)
+					r.Post("/regions", handler.createRegion)
+					r.Get("/regions/{id}", handler.getRegion)
+					r.Put("/regions/{id}", handler.updateRegion)
+					r.Delete("/regions/{id}", handler.deleteRegion)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(handler.requireScope(ScopeAudit))
+					r.Get("/audit", handler.listAudit)
+				})
+			})
 		})
 	})
 	return r
@@ -95,10 +183,17 @@ func writeResponse(w http.ResponseWriter, data interface{}) {
 	_ = json.NewEncoder(w).Encode(response) //nolint:errchkjson
 }
 
-func writeErrResponse(w http.ResponseWriter, message string, status int) {
-	response := JSONResponse{Data: []int{}, Error: &message, Code: &status}
-	w.WriteHeader(status)
+func writePageResponse(w http.ResponseWriter, data interface{}, page *models.Page) {
+	response := JSONResponse{Data: data, Meta: &Meta{Count: page.Count, NextCursor: page.NextCursor}}
+	w.Header().Set("Content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(response) //nolint:errchkjson
+}
+
+// writeErrResponse writes an error, fields list what is wrong with particular fields of the request.
+func writeErrResponse(w http.ResponseWriter, err *common.Error, fields ...models.FieldError) {
+	response := JSONResponse{Data: []int{}, Error: &err.Message, Code: &err.Status, ErrorCode: &err.Code, Fields: fields}
 	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(err.Status)
 	_ = json.NewEncoder(w).Encode(response) //nolint:errchkjson
 }
 
@@ -107,8 +202,12 @@ type JSONResponse struct {
 	Meta  *Meta       `json:"meta,omitempty"`
 	Error *string     `json:"error,omitempty"`
 	Code  *int        `json:"code,omitempty"`
+	// ErrorCode is a stable machine-readable code of the error, see pkg/common
+	ErrorCode *string             `json:"error_code,omitempty"`
+	Fields    []models.FieldError `json:"fields,omitempty"`
 }
 
 type Meta struct {
-	Count int `json:"count"`
+	Count      int64  `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -3,7 +3,7 @@ package rest
 import (
 	"context"
 	"crypto/rsa"
-	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -11,49 +11,73 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
+// Scopes grant staff access to parts of the /private API, tokens of regular users carry none.
+const (
+	ScopeReports = "reports"
+	ScopeUsers   = "users"
+	ScopeRegions = "regions"
+	ScopeAudit   = "audit"
+)
+
 type Claims struct {
 	jwt.StandardClaims
-	UUID string `json:"uuid"`
+	UUID   string   `json:"uuid"`
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type idType string
 
-const uuidKey idType = `UUID`
+const (
+	uuidKey   idType = `UUID`
+	scopesKey idType = `scopes`
+)
 
 func (h *handler) jwtAuth(next http.Handler) http.Handler {
 	var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			writeErrResponse(w, "Unauthorized", http.StatusUnauthorized)
+			h.writeErr(w, r, common.ErrUnauthenticated)
 			return
 		}
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 {
-			writeErrResponse(w, "Unauthorized", http.StatusUnauthorized)
+			h.writeErr(w, r, common.ErrUnauthenticated)
 			return
 		}
 		if headerParts[0] != "Bearer" {
-			writeErrResponse(w, "Unauthorized", http.StatusUnauthorized)
+			h.writeErr(w, r, common.ErrUnauthenticated)
 			return
 		}
-		id, err := parseToken(headerParts[1], h.key)
-		switch {
-		case err == nil:
-		case errors.Is(err, common.ErrInvalidAccessToken):
-			writeErrResponse(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		default:
-			h.log.Warnf("err parsing token: %v", err)
-			writeErrResponse(w, "Internal server error", http.StatusInternalServerError)
+		claims, err := parseToken(headerParts[1], h.key)
+		if err != nil {
+			h.writeErr(w, r, err)
 			return
 		}
-		r = r.WithContext(context.WithValue(r.Context(), uuidKey, id))
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), uuidKey, claims.UUID)
+		ctx = context.WithValue(ctx, scopesKey, claims.Scopes)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 	return fn
 }
 
-func parseToken(accessToken string, key *rsa.PublicKey) (string, error) {
+// requireScope lets through only requests authorized by jwtAuth with a token having the scope.
+func (h *handler) requireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		var fn http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+			scopes, _ := r.Context().Value(scopesKey).([]string)
+			for _, s := range scopes {
+				if s == scope {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			h.writeErr(w, r, common.ErrInsufficientScope)
+		}
+		return fn
+	}
+}
+
+func parseToken(accessToken string, key *rsa.PublicKey) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, common.ErrInvalidSigningMethod
@@ -61,10 +85,10 @@ func parseToken(accessToken string, key *rsa.PublicKey) (string, error) {
 		return key, nil
 	})
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("%w: %v", common.ErrInvalidAccessToken, err)
 	}
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UUID, nil
+		return claims, nil
 	}
-	return "", common.ErrInvalidAccessToken
+	return nil, common.ErrInvalidAccessToken
 }
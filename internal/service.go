@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/gerladeno/homie-core/pkg/chat"
 
@@ -14,60 +16,255 @@ import (
 )
 
 type Storage interface {
-	SaveConfig(ctx context.Context, config *models.Config) error
+	SaveConfig(ctx context.Context, config *models.Config, mask models.FieldMask) error
 	GetConfig(ctx context.Context, uuid string) (*models.Config, error)
+	PatchConfig(ctx context.Context, uuid string, patch func(config *models.Config) (*models.Config, models.FieldMask, error)) (*models.Config, error) //nolint:lll
 	GetRegions(ctx context.Context) ([]*models.Region, error)
-	UpsertRelation(ctx context.Context, relation *models.Relation) error
-	ListRelated(ctx context.Context, uuid string, relation storage.Relation, limit, offset int64) ([]*models.Profile, error)
-	ListMatches(ctx context.Context, uuid string, count int64) ([]*models.Profile, error)
+	GetMetro(ctx context.Context) ([]*models.MetroLine, error)
+	UpsertRelation(ctx context.Context, relation *models.Relation) (bool, error)
+	UndoRelation(ctx context.Context, uuid string, since time.Time) (*models.Relation, error)
+	ListMutualMatches(ctx context.Context, uuid string, limit, offset int64) ([]string, error)
+	ListAdmirers(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error)
+	ListRelated(ctx context.Context, uuid string, relation storage.Relation, limit int64, cursor string) ([]*models.Profile, *models.Page, error) //nolint:lll
+	ListMatches(ctx context.Context, uuid string, count int64, cursor string) ([]*models.Profile, *models.Page, error)
+	ListChats(ctx context.Context, uuid string, limit int64, cursor string) ([]string, *models.Page, error)
 	GetProfiles(ctx context.Context, uuids []string) ([]*models.Profile, error)
+	CreateListing(ctx context.Context, listing *models.Listing) error
+	UpdateListing(ctx context.Context, listing *models.Listing) error
+	DeleteListing(ctx context.Context, uuid string, id int64) error
+	GetListing(ctx context.Context, id int64) (*models.Listing, error)
+	ListListings(ctx context.Context, uuid string) ([]*models.Listing, error)
+	AddPhoto(ctx context.Context, photo *models.Photo, limit int) error
+	ListPhotos(ctx context.Context, uuid string) ([]*models.Photo, error)
+	DeletePhoto(ctx context.Context, uuid string, id int64) (*models.Photo, error)
+	ReorderPhotos(ctx context.Context, uuid string, ids []int64) error
+	PhotoVisible(ctx context.Context, viewer, key string) (bool, error)
+	DeleteAccount(ctx context.Context, uuid string) ([]*models.Photo, error)
+	ListRelations(ctx context.Context, uuid string) ([]*models.Relation, error)
+	Block(ctx context.Context, uuid, target string) error
+	Report(ctx context.Context, report *models.Report) error
+	ListReports(ctx context.Context, limit, offset int64) ([]*models.Report, error)
+	ResolveReport(ctx context.Context, id int64, resolution models.Resolution, note string, entry *models.AuditEntry) (*models.Report, error) //nolint:lll
+	Audit(ctx context.Context, entry *models.AuditEntry) error
+	ListAudit(ctx context.Context, limit, offset int64) ([]*models.AuditEntry, error)
+	SetForceHidden(ctx context.Context, uuid string, hidden bool, entry *models.AuditEntry) error
+	CreateRegion(ctx context.Context, region *models.Region, entry *models.AuditEntry) error
+	UpdateRegion(ctx context.Context, region *models.Region, entry *models.AuditEntry) error
+	DeleteRegion(ctx context.Context, id int64, entry *models.AuditEntry) error
+	GetRegion(ctx context.Context, id int64) (*models.Region, error)
+	ListAllRegions(ctx context.Context) ([]*models.Region, error)
 }
 
 type Chat interface {
-	GetDialog(ctx context.Context, client, target string) *chat.Hub
+	GetDialog(ctx context.Context, client, target string) (*chat.Hub, error)
+	LoadMessages(ctx context.Context, client, target string, before time.Time, beforeID, limit int64) ([]*chat.Message, error)
+	CloseDialogs(uuid string)
+	CloseDialog(client, target string)
 	GetAllChats(ctx context.Context, uuid string) ([]string, error)
+	EachMessage(ctx context.Context, client, target string, fn func(m *chat.Message) error) error
 }
 
+// BlobStore keeps uploaded files, URL tells where clients can download a file from.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// DefaultUndoWindow is how long a like or dislike can be undone unless WithUndoWindow is given.
+const DefaultUndoWindow = 5 * time.Minute
+
+// DefaultMaxPhotos is how many photos a gallery can hold unless WithMaxPhotos is given.
+const DefaultMaxPhotos = 6
+
 type App struct {
 	log        *logrus.Entry
 	store      Storage
 	chatServer Chat
+	blobs      BlobStore
+	undoWindow time.Duration
+	maxPhotos  int
+}
+
+type Option func(a *App)
+
+func WithUndoWindow(window time.Duration) Option {
+	return func(a *App) {
+		a.undoWindow = window
+	}
+}
+
+func WithMaxPhotos(limit int) Option {
+	return func(a *App) {
+		a.maxPhotos = limit
+	}
 }
 
-func NewApp(log *logrus.Logger, store Storage, chatServer Chat) *App {
-	return &App{
+func NewApp(log *logrus.Logger, store Storage, chatServer Chat, blobs BlobStore, opts ...Option) *App {
+	a := &App{
 		log:        log.WithField("module", "app"),
 		store:      store,
 		chatServer: chatServer,
+		blobs:      blobs,
+		undoWindow: DefaultUndoWindow,
+		maxPhotos:  DefaultMaxPhotos,
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
+	return a
 }
 
-func (a *App) GetDialog(ctx context.Context, client, target string) *chat.Hub {
-	return a.chatServer.GetDialog(ctx, client, target)
+func (a *App) GetDialog(ctx context.Context, client, target string) (*chat.Hub, error) {
+	hub, err := a.chatServer.GetDialog(ctx, client, target)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrChatForbidden):
+		return nil, common.ErrChatForbidden
+	case errors.Is(err, common.ErrConfigNotFound):
+		return nil, common.ErrConfigNotFound
+	default:
+		return nil, fmt.Errorf("err getting dialog: %w", err)
+	}
+	return hub, nil
 }
 
-func (a *App) GetAllChats(ctx context.Context, uuid string) ([]*models.Profile, error) {
-	uuids, err := a.chatServer.GetAllChats(ctx, uuid)
-	if err != nil {
-		return nil, fmt.Errorf("err getting list of uuids client chatted with: %w", err)
+func (a *App) GetAllChats(ctx context.Context, uuid string, limit int64, cursor string) ([]*models.Profile, *models.Page, error) { //nolint:lll
+	uuids, page, err := a.store.ListChats(ctx, uuid, limit, cursor)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrInvalidCursor):
+		return nil, nil, common.ErrInvalidCursor
+	default:
+		return nil, nil, fmt.Errorf("err getting list of uuids client chatted with: %w", err)
 	}
 	profiles, err := a.store.GetProfiles(ctx, uuids)
 	if err != nil {
-		return nil, fmt.Errorf("err getting list of profiles client chatted with: %w", err)
+		return nil, nil, fmt.Errorf("err getting list of profiles client chatted with: %w", err)
 	}
-	return profiles, nil
+	return profiles, page, nil
+}
+
+func (a *App) GetMessages(ctx context.Context, uuid, targetUUID string, before time.Time, beforeID, limit int64) ([]*chat.Message, error) { //nolint:lll
+	messages, err := a.chatServer.LoadMessages(ctx, uuid, targetUUID, before, beforeID, limit)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrChatForbidden):
+		return nil, common.ErrChatForbidden
+	default:
+		return nil, fmt.Errorf("err loading messages: %w", err)
+	}
+	return messages, nil
 }
 
 func (a *App) SaveConfig(ctx context.Context, config *models.Config) error {
 	if config.Personal != nil && config.Personal.Gender == models.Any {
 		return common.ErrGenderNotSpecified
 	}
-	if err := a.store.SaveConfig(ctx, config); err != nil {
+	regions, err := a.knownRegions(ctx, config)
+	if err != nil {
+		return err
+	}
+	stations, err := a.knownStations(ctx, config)
+	if err != nil {
+		return err
+	}
+	if err = config.Validate(regions, stations); err != nil {
+		return err
+	}
+	if err = a.store.SaveConfig(ctx, config, nil); err != nil {
 		return fmt.Errorf("err saving config: %w", err)
 	}
 	return nil
 }
 
+// PatchConfig applies a JSON Merge Patch to the stored config and saves only the fields it touched.
+func (a *App) PatchConfig(ctx context.Context, uuid string, patch []byte) (*models.Config, error) {
+	config, err := a.store.PatchConfig(ctx, uuid, func(config *models.Config) (*models.Config, models.FieldMask, error) {
+		config, mask, err := models.PatchConfig(config, patch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("err patching config: %w", err)
+		}
+		if config.Personal != nil && config.Personal.Gender == models.Any {
+			return nil, nil, common.ErrGenderNotSpecified
+		}
+		regions, err := a.knownRegions(ctx, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		stations, err := a.knownStations(ctx, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = config.ValidatePatch(regions, stations, mask); err != nil {
+			return nil, nil, err
+		}
+		return config, mask, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("err patching config: %w", err)
+	}
+	return config, nil
+}
+
+// DeleteAccount erases all data of uuid, disconnects its chats and deletes its photos.
+// The account can't be created again.
+func (a *App) DeleteAccount(ctx context.Context, uuid string) error {
+	photos, err := a.store.DeleteAccount(ctx, uuid)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrConfigNotFound
+	default:
+		return fmt.Errorf("err deleting account: %w", err)
+	}
+	a.chatServer.CloseDialogs(uuid)
+	for _, photo := range photos {
+		a.deletePhotoFiles(ctx, photo)
+	}
+	return nil
+}
+
+// knownRegions returns the IDs of existing regions if config has any regions to check.
+func (a *App) knownRegions(ctx context.Context, config *models.Config) (map[int64]struct{}, error) {
+	if config.Criteria == nil || len(config.Criteria.Regions) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+	return a.regionIDs(ctx)
+}
+
+// regionIDs returns the IDs of existing regions.
+func (a *App) regionIDs(ctx context.Context) (map[int64]struct{}, error) {
+	regions, err := a.store.GetRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("err getting regions to validate: %w", err)
+	}
+	known := make(map[int64]struct{}, len(regions))
+	for _, region := range regions {
+		known[region.ID] = struct{}{}
+	}
+	return known, nil
+}
+
+// knownStations returns the IDs of metro stations if config has any stations to check.
+func (a *App) knownStations(ctx context.Context, config *models.Config) (map[int64]struct{}, error) {
+	if config.Criteria == nil || len(config.Criteria.Stations) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+	lines, err := a.store.GetMetro(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("err getting stations to validate config: %w", err)
+	}
+	known := make(map[int64]struct{})
+	for _, line := range lines {
+		for _, station := range line.Stations {
+			known[station.ID] = struct{}{}
+		}
+	}
+	return known, nil
+}
+
 func (a *App) GetConfig(ctx context.Context, uuid string) (*models.Config, error) {
 	result, err := a.store.GetConfig(ctx, uuid)
 	switch {
@@ -90,7 +287,16 @@ func (a *App) GetRegions(ctx context.Context) ([]*models.Region, error) {
 	return result, nil
 }
 
-func (a *App) Like(ctx context.Context, uuid, targetUUID string, super bool) error {
+func (a *App) GetMetro(ctx context.Context) ([]*models.MetroLine, error) {
+	result, err := a.store.GetMetro(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("err getting metro: %w", err)
+	}
+	return result, nil
+}
+
+// Like saves the like and reports whether it made a mutual match.
+func (a *App) Like(ctx context.Context, uuid, targetUUID string, super bool) (bool, error) {
 	relationType := storage.Liked
 	if super {
 		relationType = storage.SuperLiked
@@ -100,10 +306,15 @@ func (a *App) Like(ctx context.Context, uuid, targetUUID string, super bool) err
 		Target:   targetUUID,
 		Relation: int8(relationType),
 	}
-	if err := a.store.UpsertRelation(ctx, &relation); err != nil {
-		return fmt.Errorf("err adding relation")
+	matched, err := a.store.UpsertRelation(ctx, &relation)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return false, common.ErrConfigNotFound
+	default:
+		return false, fmt.Errorf("err adding relation: %w", err)
 	}
-	return nil
+	return matched, nil
 }
 
 func (a *App) Dislike(ctx context.Context, uuid, targetUUID string) error {
@@ -113,36 +324,165 @@ func (a *App) Dislike(ctx context.Context, uuid, targetUUID string) error {
 		Target:   targetUUID,
 		Relation: int8(relationType),
 	}
-	if err := a.store.UpsertRelation(ctx, &relation); err != nil {
+	_, err := a.store.UpsertRelation(ctx, &relation)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrConfigNotFound
+	default:
 		return fmt.Errorf("err adding relation: %w", err)
 	}
 	return nil
 }
 
-func (a *App) ListLikedProfiles(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error) {
-	liked, err := a.store.ListRelated(ctx, uuid, storage.Liked, limit, offset)
-	if err != nil {
-		return nil, fmt.Errorf("err getting list of liked: %w", err)
+// Undo reverts the latest like or dislike of the user made within the undo window.
+func (a *App) Undo(ctx context.Context, uuid string) (*models.Relation, error) {
+	relation, err := a.store.UndoRelation(ctx, uuid, time.Now().Add(-a.undoWindow))
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrNothingToUndo):
+		return nil, common.ErrNothingToUndo
+	default:
+		return nil, fmt.Errorf("err undoing relation: %w", err)
+	}
+	return relation, nil
+}
+
+func (a *App) ListLikedProfiles(ctx context.Context, uuid string, limit int64, cursor string) ([]*models.Profile, *models.Page, error) { //nolint:lll
+	liked, page, err := a.store.ListRelated(ctx, uuid, storage.Liked, limit, cursor)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrInvalidCursor):
+		return nil, nil, common.ErrInvalidCursor
+	default:
+		return nil, nil, fmt.Errorf("err getting list of liked: %w", err)
 	}
-	return liked, nil
+	return liked, page, nil
 }
 
-func (a *App) ListDislikedProfiles(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error) {
-	disliked, err := a.store.ListRelated(ctx, uuid, storage.Disliked, limit, offset)
+func (a *App) ListDislikedProfiles(ctx context.Context, uuid string, limit int64, cursor string) ([]*models.Profile, *models.Page, error) { //nolint:lll
+	disliked, page, err := a.store.ListRelated(ctx, uuid, storage.Disliked, limit, cursor)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrInvalidCursor):
+		return nil, nil, common.ErrInvalidCursor
+	default:
+		return nil, nil, fmt.Errorf("err getting list of disliked: %w", err)
+	}
+	return disliked, page, nil
+}
+
+func (a *App) ListAdmirers(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error) {
+	admirers, err := a.store.ListAdmirers(ctx, uuid, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("err getting list of disliked: %w", err)
+		return nil, fmt.Errorf("err getting list of admirers: %w", err)
 	}
-	return disliked, nil
+	return admirers, nil
 }
 
-func (a *App) GetMatches(ctx context.Context, uuid string, count int64) ([]*models.Profile, error) {
-	matches, err := a.store.ListMatches(ctx, uuid, count)
+func (a *App) ListMutualMatches(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error) {
+	uuids, err := a.store.ListMutualMatches(ctx, uuid, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("err getting list of mutual matches: %w", err)
+	}
+	profiles, err := a.store.GetProfiles(ctx, uuids)
 	if err != nil {
-		return nil, fmt.Errorf("err getting list of matches: %w", err)
+		return nil, fmt.Errorf("err getting profiles of mutual matches: %w", err)
 	}
-	return matches, nil
+	return profiles, nil
+}
+
+func (a *App) GetMatches(ctx context.Context, uuid string, count int64, cursor string) ([]*models.Profile, *models.Page, error) { //nolint:lll
+	matches, page, err := a.store.ListMatches(ctx, uuid, count, cursor)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrInvalidCursor):
+		return nil, nil, common.ErrInvalidCursor
+	default:
+		return nil, nil, fmt.Errorf("err getting list of matches: %w", err)
+	}
+	return matches, page, nil
 }
 
 func (a *App) GetProfiles(ctx context.Context, uuids []string) ([]*models.Profile, error) {
 	return a.store.GetProfiles(ctx, uuids)
 }
+
+// CreateListing publishes a listing on behalf of its owner, only users with the listing owner role can do it.
+func (a *App) CreateListing(ctx context.Context, listing *models.Listing) error {
+	if err := a.checkListing(ctx, listing); err != nil {
+		return err
+	}
+	if err := a.store.CreateListing(ctx, listing); err != nil {
+		return fmt.Errorf("err creating listing: %w", err)
+	}
+	return nil
+}
+
+// UpdateListing replaces a listing of its owner, the owner must still have the listing owner role.
+func (a *App) UpdateListing(ctx context.Context, listing *models.Listing) error {
+	if err := a.checkListing(ctx, listing); err != nil {
+		return err
+	}
+	err := a.store.UpdateListing(ctx, listing)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrListingNotFound):
+		return common.ErrListingNotFound
+	default:
+		return fmt.Errorf("err updating listing: %w", err)
+	}
+	return nil
+}
+
+// checkListing makes sure the author of the listing is a listing owner and the listing is valid.
+func (a *App) checkListing(ctx context.Context, listing *models.Listing) error {
+	config, err := a.GetConfig(ctx, listing.UUID)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		return common.ErrNotListingOwner
+	default:
+		return fmt.Errorf("err checking listing owner: %w", err)
+	}
+	if !config.IsListingOwner() {
+		return common.ErrNotListingOwner
+	}
+	regions, err := a.regionIDs(ctx)
+	if err != nil {
+		return err
+	}
+	return listing.Validate(regions)
+}
+
+func (a *App) DeleteListing(ctx context.Context, uuid string, id int64) error {
+	err := a.store.DeleteListing(ctx, uuid, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrListingNotFound):
+		return common.ErrListingNotFound
+	default:
+		return fmt.Errorf("err deleting listing: %w", err)
+	}
+	return nil
+}
+
+func (a *App) GetListing(ctx context.Context, id int64) (*models.Listing, error) {
+	listing, err := a.store.GetListing(ctx, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrListingNotFound):
+		return nil, common.ErrListingNotFound
+	default:
+		return nil, fmt.Errorf("err getting listing: %w", err)
+	}
+	return listing, nil
+}
+
+func (a *App) ListListings(ctx context.Context, uuid string) ([]*models.Listing, error) {
+	listings, err := a.store.ListListings(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("err getting list of listings: %w", err)
+	}
+	return listings, nil
+}
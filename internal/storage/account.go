@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+// DeleteAccount erases everything stored about uuid in one transaction. The config row stays as a tombstone,
+// so the account can't be recreated and never shows up in matches. It returns the photos of the account,
+// whose files are to be deleted.
+func (s *Storage) DeleteAccount(ctx context.Context, uuid string) ([]*models.Photo, error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("err deleting account: %w", err)
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Warnf("err rolling back tx during deleting account: %v", err)
+		}
+	}()
+	var locked string
+	err = tx.QueryRow(ctx, `SELECT uuid FROM config WHERE uuid = $1 AND deleted IS NULL FOR UPDATE`, uuid).Scan(&locked)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, common.ErrConfigNotFound
+	default:
+		return nil, fmt.Errorf("err locking config of %s: %w", uuid, err)
+	}
+	var photos []*models.Photo
+	err = pgxscan.Select(ctx, tx, &photos, `DELETE FROM photos WHERE uuid = $1 RETURNING `+photoColumns, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("err deleting photos of %s: %w", uuid, err)
+	}
+	queries := []string{
+		`DELETE FROM uuid_regions WHERE uuid = $1`,
+		`DELETE FROM uuid_stations WHERE uuid = $1`,
+		`DELETE FROM search_criteria WHERE uuid = $1`,
+		`DELETE FROM personal WHERE uuid = $1`,
+		`DELETE FROM settings WHERE uuid = $1`,
+		`DELETE FROM listings WHERE uuid = $1`,
+		`DELETE FROM relation_events WHERE uuid = $1 OR target = $1`,
+		`DELETE FROM relations WHERE uuid = $1 OR target = $1`,
+		`DELETE FROM mutual_matches WHERE uuid1 = $1 OR uuid2 = $1`,
+		// blocks and reports about the account stay, so that moderators can still resolve them
+		`DELETE FROM blocks WHERE uuid = $1`,
+		`DELETE FROM reports WHERE uuid = $1`,
+		`DELETE FROM message WHERE sender = $1 OR receiver = $1`,
+		`DELETE FROM chat WHERE uuid1 = $1 OR uuid2 = $1`,
+		`UPDATE config SET role = 0, deleted = now(), updated = now() WHERE uuid = $1`,
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(ctx, query, uuid); err != nil {
+			return nil, fmt.Errorf("err erasing data of %s: %w", uuid, err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("err committing account deletion: %w", err)
+	}
+	return photos, nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+// Audit records the entry in the audit log and sets its ID and creation time.
+func (s *Storage) Audit(ctx context.Context, entry *models.AuditEntry) error {
+	return s.audited(ctx, entry, func(tx pgx.Tx) error {
+		return nil
+	})
+}
+
+// ListAudit returns the audit log, the latest entries first.
+func (s *Storage) ListAudit(ctx context.Context, limit, offset int64) ([]*models.AuditEntry, error) {
+	var entries []*models.AuditEntry
+	err := pgxscan.Select(ctx, s.db, &entries, `
+SELECT id, actor, action, target, details, created
+FROM audit_log
+ORDER BY created DESC, id DESC
+LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("err selecting audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// SetForceHidden hides or shows the profile of uuid regardless of its settings.
+func (s *Storage) SetForceHidden(ctx context.Context, uuid string, hidden bool, entry *models.AuditEntry) error {
+	return s.audited(ctx, entry, func(tx pgx.Tx) error {
+		res, err := tx.Exec(ctx, `UPDATE config SET force_hidden = $2 WHERE uuid = $1 AND deleted IS NULL`, uuid, hidden)
+		if err != nil {
+			return fmt.Errorf("err hiding profile of %s: %w", uuid, err)
+		}
+		if res.RowsAffected() == 0 {
+			return common.ErrConfigNotFound
+		}
+		entry.Target = uuid
+		return nil
+	})
+}
+
+// audited runs fn and records the entry in one transaction, so an action is never left out of the log.
+func (s *Storage) audited(ctx context.Context, entry *models.AuditEntry, fn func(tx pgx.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("err starting audited tx: %w", err)
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Warnf("err rolling back tx during %s: %v", entry.Action, err)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = s.insertAuditEntry(ctx, tx, entry); err != nil {
+		return fmt.Errorf("err auditing %s by %s: %w", entry.Action, entry.Actor, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("err committing %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+func (s *Storage) insertAuditEntry(ctx context.Context, tx pgx.Tx, entry *models.AuditEntry) error {
+	details := "{}"
+	if len(entry.Details) > 0 {
+		details = string(entry.Details)
+	}
+	row := tx.QueryRow(ctx, `
+INSERT INTO audit_log (actor, action, target, details, created)
+VALUES ($1, $2, $3, $4, now())
+RETURNING id, created`, entry.Actor, entry.Action, entry.Target, details)
+	return row.Scan(&entry.ID, &entry.Created)
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/chat"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+func (s *Storage) SaveChat(ctx context.Context, uuid1, uuid2 string) error {
+	uuid1, uuid2 = orderedPair(uuid1, uuid2)
+	query := `
+INSERT INTO chat (uuid1, uuid2, created, updated)
+SELECT $1, $2, $3, $4
+WHERE (SELECT count(*) FROM config WHERE uuid IN ($1, $2) AND deleted IS NULL) = 2
+ON CONFLICT (uuid1, uuid2) DO UPDATE SET updated = EXCLUDED.updated
+`
+	t := time.Now().UTC()
+	res, err := s.db.Exec(ctx, query, uuid1, uuid2, t, t)
+	if err != nil {
+		return fmt.Errorf("err inserting chat for %s and %s: %w", uuid1, uuid2, err)
+	}
+	if res.RowsAffected() == 0 {
+		// one of the accounts is deleted
+		return common.ErrConfigNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetChat(ctx context.Context, uuid1, uuid2 string) error {
+	uuid1, uuid2 = orderedPair(uuid1, uuid2)
+	row := s.db.QueryRow(ctx, `SELECT uuid1 FROM chat WHERE uuid1 = $1 AND uuid2 = $2`, uuid1, uuid2)
+	scannedUUID := ""
+	err := row.Scan(&scannedUUID)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return common.ErrChatNotFound
+	default:
+		return fmt.Errorf("err getting chat for %s and %s: %w", uuid1, uuid2, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetAllChats(ctx context.Context, uuid string) ([]string, error) {
+	var uuids []string
+	err := pgxscan.Select(ctx, s.db, &uuids, `
+SELECT CASE WHEN uuid1 = $1 THEN uuid2 ELSE uuid1 END AS uuid
+FROM chat
+WHERE uuid1 = $1
+   OR uuid2 = $1
+ORDER BY updated DESC`, uuid)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("err selecting chats for %s: %w", uuid, err)
+	}
+	return uuids, nil
+}
+
+func (s *Storage) SaveMessage(ctx context.Context, m *chat.Message) error {
+	if m == nil {
+		return nil
+	}
+	timestamp := time.Now().UTC()
+	if m.Timestamp != "" {
+		var err error
+		if timestamp, err = time.Parse(time.RFC3339Nano, m.Timestamp); err != nil {
+			return fmt.Errorf("err parsing message timestamp %s: %w", m.Timestamp, err)
+		}
+	}
+	query := `
+INSERT INTO message (sender, receiver, timestamp, body)
+VALUES ($1, $2, $3, $4)
+RETURNING id
+`
+	row := s.db.QueryRow(ctx, query, m.Sender, m.Receiver, timestamp.UTC(), m.Body)
+	if err := row.Scan(&m.ID); err != nil {
+		return fmt.Errorf("err inserting message from %s to %s: %w", m.Sender, m.Receiver, err)
+	}
+	return nil
+}
+
+// ListChats returns uuids of users the given one chats with, the latest chats first.
+// Chats with blocked users are skipped.
+func (s *Storage) ListChats(ctx context.Context, uuid string, limit int64, cursor string) ([]string, *models.Page, error) {
+	after, err := models.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []keysetRow
+	err = pgxscan.Select(ctx, s.db, &rows, `
+SELECT uuid, ts
+FROM (SELECT CASE WHEN uuid1 = $1 THEN uuid2 ELSE uuid1 END AS uuid, updated AS ts
+      FROM chat
+      WHERE (uuid1 = $1 OR uuid2 = $1)
+        AND NOT blocked(uuid1, uuid2)) AS chats
+WHERE $2::timestamp IS NULL
+   OR (ts, uuid) < ($2, $3)
+ORDER BY ts DESC, uuid DESC
+LIMIT $4`, uuid, afterTimestamp(after), afterUUID(after), fetchLimit(limit))
+	if err != nil {
+		return nil, nil, fmt.Errorf("err selecting chats for %s: %w", uuid, err)
+	}
+	page := models.Page{}
+	row := s.db.QueryRow(ctx, `
+SELECT count(*)
+FROM chat
+WHERE (uuid1 = $1 OR uuid2 = $1)
+  AND NOT blocked(uuid1, uuid2)`, uuid)
+	if err = row.Scan(&page.Count); err != nil {
+		return nil, nil, fmt.Errorf("err counting chats for %s: %w", uuid, err)
+	}
+	return nextPage(rows, limit, &page), &page, nil
+}
+
+func (s *Storage) LoadAllMessages(ctx context.Context, uuid1, uuid2 string) ([]*chat.Message, error) {
+	var dbMessages []Message
+	err := pgxscan.Select(ctx, s.db, &dbMessages, `
+SELECT id, sender, receiver, timestamp, body
+FROM message
+WHERE (sender = $1 AND receiver = $2)
+   OR (sender = $2 AND receiver = $1)
+ORDER BY timestamp, id`, uuid1, uuid2)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("err loading messages for %s and %s: %w", uuid1, uuid2, err)
+	}
+	result := make([]*chat.Message, 0, len(dbMessages))
+	for i := range dbMessages {
+		result = append(result, DBMessage2Message(&dbMessages[i]))
+	}
+	return result, nil
+}
+
+// exportPageSize is how many messages EachMessage reads at once.
+const exportPageSize = 500
+
+// EachMessage calls fn for every message of the dialog, oldest first. Messages are read page by page, so no
+// connection is held while fn runs.
+func (s *Storage) EachMessage(ctx context.Context, uuid1, uuid2 string, fn func(m *chat.Message) error) error {
+	var after time.Time
+	var afterID int64
+	for {
+		var dbMessages []Message
+		err := pgxscan.Select(ctx, s.db, &dbMessages, `
+SELECT id, sender, receiver, timestamp, body
+FROM message
+WHERE ((sender = $1 AND receiver = $2) OR (sender = $2 AND receiver = $1))
+  AND (timestamp, id) > ($3, $4)
+ORDER BY timestamp, id
+LIMIT $5`, uuid1, uuid2, after, afterID, exportPageSize)
+		if err != nil {
+			return fmt.Errorf("err loading messages for %s and %s: %w", uuid1, uuid2, err)
+		}
+		for i := range dbMessages {
+			if err = fn(DBMessage2Message(&dbMessages[i])); err != nil {
+				return err
+			}
+		}
+		if len(dbMessages) < exportPageSize {
+			return nil
+		}
+		last := dbMessages[len(dbMessages)-1]
+		after, afterID = last.Timestamp, last.ID
+	}
+}
+
+// LoadMessages returns up to limit messages of the dialog sent strictly before the message with the given
+// time and id, oldest first, so the timestamp and the id of the first one can be used as the next cursor.
+// An id of 0 means all the messages sent before the given time.
+func (s *Storage) LoadMessages(ctx context.Context, uuid1, uuid2 string, before time.Time, beforeID, limit int64) ([]*chat.Message, error) { //nolint:lll
+	var dbMessages []Message
+	err := pgxscan.Select(ctx, s.db, &dbMessages, `
+SELECT id, sender, receiver, timestamp, body
+FROM (SELECT id, sender, receiver, timestamp, body
+      FROM message
+      WHERE ((sender = $1 AND receiver = $2) OR (sender = $2 AND receiver = $1))
+        AND (timestamp, id) < ($3, $4)
+      ORDER BY timestamp DESC, id DESC
+      LIMIT $5) AS page
+ORDER BY timestamp, id`, uuid1, uuid2, before.UTC(), beforeID, limit)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("err loading messages for %s and %s: %w", uuid1, uuid2, err)
+	}
+	result := make([]*chat.Message, 0, len(dbMessages))
+	for i := range dbMessages {
+		result = append(result, DBMessage2Message(&dbMessages[i]))
+	}
+	return result, nil
+}
+
+// orderedPair keeps chat rows unique regardless of who started the dialog.
+func orderedPair(uuid1, uuid2 string) (string, string) {
+	if uuid1 > uuid2 {
+		return uuid2, uuid1
+	}
+	return uuid1, uuid2
+}
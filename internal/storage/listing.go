@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+const listingColumns = `id, uuid, region_id, rent, rooms, available_from, photos, description,
+       CASE WHEN lat IS NULL THEN NULL ELSE json_build_object('lat', lat, 'lon', lon) END AS location`
+
+func (s *Storage) CreateListing(ctx context.Context, listing *models.Listing) error {
+	query := `
+INSERT INTO listings (uuid, region_id, rent, rooms, available_from, photos, description, lat, lon, created, updated)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id
+`
+	t := time.Now()
+	lat, lon := pointColumns(listing.Location)
+	row := s.db.QueryRow(ctx, query, listing.UUID, listing.RegionID, listing.Rent, listing.Rooms,
+		listing.AvailableFrom, listing.Photos, listing.Description, lat, lon, t, t)
+	if err := row.Scan(&listing.ID); err != nil {
+		return fmt.Errorf("err inserting listing for %s: %w", listing.UUID, err)
+	}
+	return nil
+}
+
+func (s *Storage) UpdateListing(ctx context.Context, listing *models.Listing) error {
+	query := `
+UPDATE listings
+SET region_id = $3,
+    rent = $4,
+    rooms = $5,
+    available_from = $6,
+    photos = $7,
+    description = $8,
+    lat = $9,
+    lon = $10,
+    updated = $11
+WHERE id = $1 AND uuid = $2
+`
+	lat, lon := pointColumns(listing.Location)
+	res, err := s.db.Exec(ctx, query, listing.ID, listing.UUID, listing.RegionID, listing.Rent, listing.Rooms,
+		listing.AvailableFrom, listing.Photos, listing.Description, lat, lon, time.Now())
+	if err != nil {
+		return fmt.Errorf("err updating listing %d: %w", listing.ID, err)
+	}
+	if res.RowsAffected() == 0 {
+		return common.ErrListingNotFound
+	}
+	return nil
+}
+
+func (s *Storage) DeleteListing(ctx context.Context, uuid string, id int64) error {
+	res, err := s.db.Exec(ctx, `DELETE FROM listings WHERE id = $1 AND uuid = $2`, id, uuid)
+	if err != nil {
+		return fmt.Errorf("err deleting listing %d: %w", id, err)
+	}
+	if res.RowsAffected() == 0 {
+		return common.ErrListingNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetListing(ctx context.Context, id int64) (*models.Listing, error) {
+	var listing models.Listing
+	err := pgxscan.Get(ctx, s.db, &listing, `SELECT `+listingColumns+` FROM listings WHERE id = $1`, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, common.ErrListingNotFound
+	default:
+		return nil, fmt.Errorf("err getting listing %d: %w", id, err)
+	}
+	return &listing, nil
+}
+
+func (s *Storage) ListListings(ctx context.Context, uuid string) ([]*models.Listing, error) {
+	var listings []*models.Listing
+	err := pgxscan.Select(ctx, s.db, &listings,
+		`SELECT `+listingColumns+` FROM listings WHERE uuid = $1 ORDER BY id`, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("err listing listings of %s: %w", uuid, err)
+	}
+	return listings, nil
+}
+
+// listingsFor returns listings of the given owners whose rent and region or distance fit the criteria of uuid,
+// grouped by owner.
+func (s *Storage) listingsFor(ctx context.Context, uuid string, owners []string) (map[string][]*models.Listing, error) {
+	var listings []*models.Listing
+	err := pgxscan.Select(ctx, s.db, &listings, `
+SELECT `+listingColumns+`
+FROM listings
+WHERE uuid = ANY ($2)
+  AND (region_id IN (SELECT related_regions(array(SELECT region_id FROM uuid_regions WHERE uuid = $1)))
+    OR EXISTS(SELECT 1
+              FROM search_criteria c
+              WHERE c.uuid = $1
+                AND distance_km(c.lat, c.lon, listings.lat, listings.lon) <= c.max_distance_km))
+  AND rent >= (SELECT COALESCE(price_from, 0) FROM search_criteria WHERE uuid = $1)
+  AND rent <= (SELECT COALESCE(price_to, 999999999999) FROM search_criteria WHERE uuid = $1)
+ORDER BY rent, id`, uuid, owners)
+	if err != nil {
+		return nil, fmt.Errorf("err selecting listings for %s: %w", uuid, err)
+	}
+	result := make(map[string][]*models.Listing)
+	for _, listing := range listings {
+		result[listing.UUID] = append(result[listing.UUID], listing)
+	}
+	return result, nil
+}
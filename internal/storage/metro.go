@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+)
+
+// DefaultStationStops is how many stops away from the preferred stations of a user other users are matched.
+const DefaultStationStops = 3
+
+// WithStationStops makes users match the users preferring stations up to stops stops away from their ones.
+func WithStationStops(stops int) Option {
+	return func(s *Storage) {
+		s.stationStops = stops
+	}
+}
+
+// GetMetro returns the metro lines with their stations.
+func (s *Storage) GetMetro(ctx context.Context) ([]*models.MetroLine, error) {
+	var lines []*models.MetroLine
+	err := pgxscan.Select(ctx, s.db, &lines, `SELECT id, name, color, circular FROM metro_lines ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("err getting metro lines: %w", err)
+	}
+	var stations []*models.Station
+	err = pgxscan.Select(ctx, s.db, &stations,
+		`SELECT id, line_id, position, name, region_id FROM metro_stations ORDER BY line_id, position`)
+	if err != nil {
+		return nil, fmt.Errorf("err getting metro stations: %w", err)
+	}
+	byID := make(map[int64]*models.MetroLine, len(lines))
+	for _, line := range lines {
+		line.Stations = []*models.Station{}
+		byID[line.ID] = line
+	}
+	for _, station := range stations {
+		if line, ok := byID[station.LineID]; ok {
+			line.Stations = append(line.Stations, station)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,11 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+create index message_timestamp_idx
+    on message (sender, receiver, timestamp);
+
+-- +migrate Down
+
+DROP INDEX message_timestamp_idx;
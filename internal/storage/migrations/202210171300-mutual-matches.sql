@@ -0,0 +1,30 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+create table mutual_matches
+(
+    uuid1   text not null
+        constraint fk_mutual_matches_uuid1
+            references config,
+    uuid2   text not null
+        constraint fk_mutual_matches_uuid2
+            references config,
+    created timestamp default now(),
+    primary key (uuid1, uuid2)
+);
+
+create index mutual_matches_uuid2_idx on mutual_matches (uuid2);
+
+INSERT INTO mutual_matches (uuid1, uuid2)
+SELECT r1.uuid, r1.target
+FROM relations r1
+         JOIN relations r2 ON r1.uuid = r2.target AND r1.target = r2.uuid
+WHERE r1.uuid < r1.target
+  AND r1.relation IN (0, 1)
+  AND r2.relation IN (0, 1);
+
+-- +migrate Down
+
+DROP TABLE mutual_matches CASCADE;
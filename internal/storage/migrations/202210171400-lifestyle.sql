@@ -0,0 +1,44 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table personal
+    add column smoking        smallint not null default 0,
+    add column pets           smallint not null default 0,
+    add column sleep_schedule smallint not null default 0,
+    add column cleanliness    smallint not null default 0,
+    add column guests         smallint not null default 0;
+
+alter table search_criteria
+    add column smoking                   smallint not null default 0,
+    add column smoking_importance        smallint not null default 0,
+    add column pets                      smallint not null default 0,
+    add column pets_importance           smallint not null default 0,
+    add column sleep_schedule            smallint not null default 0,
+    add column sleep_schedule_importance smallint not null default 0,
+    add column cleanliness               smallint not null default 0,
+    add column cleanliness_importance    smallint not null default 0,
+    add column guests                    smallint not null default 0,
+    add column guests_importance         smallint not null default 0;
+
+-- +migrate Down
+
+alter table personal
+    drop column smoking,
+    drop column pets,
+    drop column sleep_schedule,
+    drop column cleanliness,
+    drop column guests;
+
+alter table search_criteria
+    drop column smoking,
+    drop column smoking_importance,
+    drop column pets,
+    drop column pets_importance,
+    drop column sleep_schedule,
+    drop column sleep_schedule_importance,
+    drop column cleanliness,
+    drop column cleanliness_importance,
+    drop column guests,
+    drop column guests_importance;
@@ -0,0 +1,35 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table config
+    add column role smallint not null default 0;
+
+create table listings
+(
+    id             bigserial
+        primary key,
+    uuid           text     not null
+        constraint fk_configs_listings
+            references config,
+    region_id      bigint   not null
+        constraint fk_listings_region
+            references regions,
+    rent           numeric  not null,
+    rooms          smallint not null default 1,
+    available_from date     not null default now(),
+    photos         text[],
+    description    text     not null default '',
+    created        timestamp default now(),
+    updated        timestamp default now()
+);
+
+create index listings_uuid_idx on listings (uuid);
+create index listings_region_rent_idx on listings (region_id, rent);
+
+-- +migrate Down
+
+DROP TABLE listings CASCADE;
+alter table config
+    drop column role;
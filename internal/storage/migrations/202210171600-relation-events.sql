@@ -0,0 +1,26 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+create table relation_events
+(
+    id       bigserial
+        primary key,
+    uuid     text not null
+        constraint fk_configs_relation_events_uuid
+            references config,
+    target   text not null
+        constraint fk_configs_relation_events_target
+            references config,
+    relation smallint not null,
+    previous smallint,
+    undone   boolean  not null default false,
+    created  timestamp default now()
+);
+
+create index relation_events_uuid_created_idx on relation_events (uuid, created);
+
+-- +migrate Down
+
+DROP TABLE relation_events CASCADE;
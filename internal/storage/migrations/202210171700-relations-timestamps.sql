@@ -0,0 +1,26 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table relations
+    add column created timestamp default now(),
+    add column updated timestamp default now();
+
+alter table personal
+    add column updated timestamp default now();
+
+alter table search_criteria
+    add column updated timestamp default now();
+
+-- +migrate Down
+
+alter table relations
+    drop column created,
+    drop column updated;
+
+alter table personal
+    drop column updated;
+
+alter table search_criteria
+    drop column updated;
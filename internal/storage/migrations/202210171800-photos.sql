@@ -0,0 +1,25 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+create table photos
+(
+    id            bigserial
+        primary key,
+    uuid          text      not null
+        constraint fk_configs_photos
+            references config,
+    position      smallint  not null,
+    key           text      not null,
+    thumbnail_key text      not null,
+    url           text      not null,
+    thumbnail_url text      not null,
+    created       timestamp not null default now()
+);
+
+create index photos_uuid_position_idx on photos (uuid, position);
+
+-- +migrate Down
+
+DROP TABLE photos;
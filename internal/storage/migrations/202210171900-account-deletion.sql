@@ -0,0 +1,12 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table config
+    add column deleted timestamp;
+
+-- +migrate Down
+
+alter table config
+    drop column deleted;
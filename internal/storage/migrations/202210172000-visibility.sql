@@ -0,0 +1,28 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table settings
+    add column visibility   smallint default 0 not null,
+    add column hidden_until timestamptz;
+
+-- visible tells whether a profile with the given settings is shown to others:
+-- 0 - active, 1 - paused, 2 - hidden until hidden_until
+-- +migrate StatementBegin
+create function visible(visibility smallint, hidden_until timestamptz) returns boolean
+    language sql
+    stable
+as
+$$
+SELECT visibility = 0 OR visibility = 2 AND (hidden_until IS NULL OR hidden_until <= now())
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+drop function visible(smallint, timestamptz);
+
+alter table settings
+    drop column visibility,
+    drop column hidden_until;
@@ -0,0 +1,57 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+create table blocks
+(
+    uuid    text not null
+        constraint fk_blocks_uuid
+            references config,
+    target  text not null
+        constraint fk_blocks_target
+            references config,
+    created timestamp default now(),
+    primary key (uuid, target)
+);
+
+create index blocks_target_idx on blocks (target);
+
+-- blocked tells whether either of the users has blocked the other one
+-- +migrate StatementBegin
+create function blocked(uuid1 text, uuid2 text) returns boolean
+    language sql
+    stable
+as
+$$
+SELECT EXISTS(SELECT 1
+              FROM blocks
+              WHERE (uuid = uuid1 AND target = uuid2)
+                 OR (uuid = uuid2 AND target = uuid1))
+$$;
+-- +migrate StatementEnd
+
+create table reports
+(
+    id         bigserial primary key,
+    uuid       text     not null
+        constraint fk_reports_uuid
+            references config,
+    target     text     not null
+        constraint fk_reports_target
+            references config,
+    reason     smallint not null,
+    comment    text     not null default '',
+    created    timestamp         default now(),
+    resolution smallint not null default 0,
+    note       text     not null default '',
+    resolved   timestamp
+);
+
+create index reports_queue_idx on reports (created) where resolved is null;
+
+-- +migrate Down
+
+DROP TABLE reports CASCADE;
+DROP FUNCTION blocked(text, text);
+DROP TABLE blocks CASCADE;
@@ -0,0 +1,42 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table config
+    add column force_hidden boolean default false not null;
+
+-- shown tells whether the profile of uuid is shown to others: neither the user nor a moderator has hidden it
+-- +migrate StatementBegin
+create function shown(profile text) returns boolean
+    language sql
+    stable
+as
+$$
+SELECT NOT EXISTS(SELECT 1 FROM config WHERE uuid = profile AND force_hidden)
+           AND NOT EXISTS(SELECT 1 FROM settings WHERE uuid = profile AND NOT visible(visibility, hidden_until))
+$$;
+-- +migrate StatementEnd
+
+create table audit_log
+(
+    id      bigserial primary key,
+    actor   text not null,
+    action  text not null,
+    target  text not null default '',
+    details jsonb not null default '{}',
+    created timestamp default now()
+);
+
+create index audit_log_created_idx on audit_log (created);
+
+-- regions were inserted with explicit ids
+SELECT setval(pg_get_serial_sequence('regions', 'id'), (SELECT max(id) FROM regions));
+
+-- +migrate Down
+
+DROP TABLE audit_log CASCADE;
+DROP FUNCTION shown(text);
+
+alter table config
+    drop column force_hidden;
@@ -0,0 +1,69 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table regions
+    add column parent_id bigint
+        constraint fk_regions_parent
+            references regions;
+
+create index regions_parent_id_idx on regions (parent_id);
+
+WITH city AS (INSERT INTO regions (name, description, created_at, updated_at)
+    VALUES ('Москва', '', now(), now())
+    RETURNING id)
+UPDATE regions
+SET parent_id = (SELECT id FROM city)
+WHERE id BETWEEN 1 AND 12;
+
+-- related_regions returns the regions overlapping any of ids: the regions themselves, the regions inside
+-- them and the regions containing them. Deleted regions overlap nothing.
+-- +migrate StatementBegin
+create function related_regions(ids bigint[]) returns setof bigint
+    language sql
+    stable
+as
+$$
+WITH RECURSIVE descendants AS (SELECT r.id
+                               FROM regions r
+                               WHERE r.id = ANY (ids)
+                                 AND r.deleted_at IS NULL
+                               UNION
+                               SELECT r.id
+                               FROM regions r
+                                        JOIN descendants d ON r.parent_id = d.id
+                               WHERE r.deleted_at IS NULL),
+               ancestors AS (SELECT r.id, r.parent_id
+                             FROM regions r
+                             WHERE r.id = ANY (ids)
+                               AND r.deleted_at IS NULL
+                             UNION
+                             SELECT r.id, r.parent_id
+                             FROM regions r
+                                      JOIN ancestors a ON r.id = a.parent_id
+                             WHERE r.deleted_at IS NULL)
+SELECT id
+FROM descendants
+UNION
+SELECT id
+FROM ancestors
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+DROP FUNCTION related_regions(bigint[]);
+
+UPDATE regions
+SET parent_id = NULL
+WHERE id BETWEEN 1 AND 12;
+
+DELETE
+FROM regions
+WHERE name = 'Москва'
+  AND parent_id IS NULL
+  AND id > 12;
+
+alter table regions
+    drop column parent_id;
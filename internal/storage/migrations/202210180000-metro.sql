@@ -0,0 +1,107 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- filled by 202210180400-metro-data.sql
+create table metro_lines
+(
+    id       bigint  not null
+        primary key,
+    name     text    not null,
+    color    text    not null,
+    circular boolean not null default false
+);
+
+create table metro_stations
+(
+    id        bigint not null
+        primary key,
+    line_id   bigint not null
+        constraint fk_metro_stations_line
+            references metro_lines,
+    position  int    not null,
+    name      text   not null,
+    region_id bigint
+        constraint fk_metro_stations_region
+            references regions,
+    unique (line_id, position)
+);
+
+create table metro_transfers
+(
+    station_id bigint not null
+        constraint fk_metro_transfers_station
+            references metro_stations,
+    other_id   bigint not null
+        constraint fk_metro_transfers_other
+            references metro_stations,
+    primary key (station_id, other_id)
+);
+
+create table uuid_stations
+(
+    uuid       text   not null
+        constraint fk_uuid_stations_search_criteria
+            references search_criteria,
+    station_id bigint not null
+        constraint fk_uuid_stations_station
+            references metro_stations,
+    primary key (uuid, station_id)
+);
+
+create index uuid_stations_station_id_idx on uuid_stations (station_id);
+
+-- metro_hops are the rides between neighbouring stations of a line, which cost a stop,
+-- and the free changes between lines.
+create view metro_hops as
+SELECT a.id AS station_id, b.id AS next_id, 1 AS stops
+FROM metro_stations a
+         JOIN metro_stations b ON b.line_id = a.line_id AND abs(b.position - a.position) = 1
+UNION ALL
+-- the first and the last stations of a circular line are neighbours too
+SELECT a.id, b.id, 1
+FROM metro_lines l
+         JOIN metro_stations a ON a.line_id = l.id
+         JOIN metro_stations b ON b.line_id = l.id
+WHERE l.circular
+  AND abs(b.position - a.position) = (SELECT count(*) - 1 FROM metro_stations s WHERE s.line_id = l.id)
+UNION ALL
+SELECT station_id, other_id, 0
+FROM metro_transfers
+UNION ALL
+SELECT other_id, station_id, 0
+FROM metro_transfers;
+
+-- nearby_stations returns the stations on the lines of ids and the stations within max_stops stops of them.
+-- +migrate StatementBegin
+create function nearby_stations(ids bigint[], max_stops int) returns setof bigint
+    language sql
+    stable
+as
+$$
+WITH RECURSIVE reached AS (SELECT id, 0 AS stops
+                           FROM metro_stations
+                           WHERE id = ANY (ids)
+                           UNION
+                           SELECT h.next_id, r.stops + h.stops
+                           FROM reached r
+                                    JOIN metro_hops h ON h.station_id = r.id
+                           WHERE r.stops + h.stops <= max_stops)
+SELECT id
+FROM metro_stations
+WHERE line_id IN (SELECT line_id FROM metro_stations WHERE id = ANY (ids))
+UNION
+SELECT id
+FROM reached
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+DROP FUNCTION nearby_stations(bigint[], int);
+DROP VIEW metro_hops;
+DROP TABLE uuid_stations;
+DROP TABLE metro_transfers;
+DROP TABLE metro_stations;
+DROP TABLE metro_lines;
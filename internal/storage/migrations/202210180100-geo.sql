@@ -0,0 +1,73 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+alter table search_criteria
+    add column lat             double precision,
+    add column lon             double precision,
+    add column max_distance_km double precision;
+
+alter table listings
+    add column lat double precision,
+    add column lon double precision;
+
+-- a GeoJSON Polygon or MultiPolygon
+alter table regions
+    add column boundary jsonb;
+
+-- points are (lon, lat), so bounding boxes find them without PostGIS
+create index search_criteria_location_idx on search_criteria using gist (point(lon, lat));
+create index listings_location_idx on listings using gist (point(lon, lat));
+
+-- distance_km returns the great-circle distance between two points computed by the haversine formula.
+-- +migrate StatementBegin
+create function distance_km(lat1 double precision, lon1 double precision,
+                            lat2 double precision, lon2 double precision) returns double precision
+    language sql
+    immutable
+as
+$$
+SELECT 2 * 6371 * asin(sqrt(least(1, power(sin(radians(lat2 - lat1) / 2), 2) +
+                                     cos(radians(lat1)) * cos(radians(lat2)) *
+                                     power(sin(radians(lon2 - lon1) / 2), 2))))
+$$;
+-- +migrate StatementEnd
+
+-- bounding_box returns a (lon, lat) box containing all the points within km of the given one.
+-- Near the poles it spans all longitudes, it isn't split at the antimeridian.
+-- +migrate StatementBegin
+create function bounding_box(lat double precision, lon double precision, km double precision) returns box
+    language sql
+    immutable
+as
+$$
+SELECT CASE
+           WHEN cos(radians(lat)) <= sin(km / 6371)
+               THEN box(point(-180, greatest(lat - degrees(km / 6371), -90)),
+                        point(180, least(lat + degrees(km / 6371), 90)))
+           ELSE box(point(lon - degrees(asin(sin(km / 6371) / cos(radians(lat)))), lat - degrees(km / 6371)),
+                    point(lon + degrees(asin(sin(km / 6371) / cos(radians(lat)))), lat + degrees(km / 6371)))
+           END
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+DROP FUNCTION bounding_box(double precision, double precision, double precision);
+DROP FUNCTION distance_km(double precision, double precision, double precision, double precision);
+
+DROP INDEX listings_location_idx;
+DROP INDEX search_criteria_location_idx;
+
+alter table regions
+    drop column boundary;
+
+alter table listings
+    drop column lat,
+    drop column lon;
+
+alter table search_criteria
+    drop column lat,
+    drop column lon,
+    drop column max_distance_km;
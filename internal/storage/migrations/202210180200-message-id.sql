@@ -0,0 +1,18 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- id breaks ties between messages sent at the same time, so the history can be paged without gaps or repeats
+alter table message
+    add column id bigserial primary key;
+
+create index message_timestamp_id_idx
+    on message (sender, receiver, timestamp, id);
+
+-- +migrate Down
+
+DROP INDEX message_timestamp_id_idx;
+
+alter table message
+    drop column id;
@@ -0,0 +1,165 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- ranges_overlap returns the length of the intersection of two ranges relative to the shorter one.
+-- Missing bounds are treated as open.
+-- +migrate StatementBegin
+create function ranges_overlap(a_from numeric, a_to numeric, b_from numeric, b_to numeric) returns double precision
+    language sql
+    immutable
+as
+$$
+SELECT CASE
+           WHEN hi < lo THEN 0
+           WHEN shorter = 'infinity' OR shorter = 0 THEN 1
+           ELSE least((hi - lo) / shorter, 1)
+           END
+FROM (SELECT greatest(coalesce(a_from, 0), coalesce(b_from, 0))::double precision AS lo,
+             least(coalesce(a_to::double precision, 'infinity'),
+                   coalesce(b_to::double precision, 'infinity'))                  AS hi,
+             least(coalesce(a_to::double precision, 'infinity') - coalesce(a_from, 0),
+                   coalesce(b_to::double precision, 'infinity') - coalesce(b_from, 0)) AS shorter) AS bounds
+$$;
+-- +migrate StatementEnd
+
+-- age_fit is 1 in the middle of the range and decreases to 0.5 at its bounds, 0 outside of it.
+-- +migrate StatementBegin
+create function age_fit(age_from numeric, age_to numeric, age smallint) returns double precision
+    language sql
+    immutable
+as
+$$
+SELECT CASE
+           WHEN coalesce(age, 0) < coalesce(age_from, 0) OR
+                coalesce(age, 0) > coalesce(age_to::double precision, 'infinity') THEN 0
+           WHEN age_from IS NULL OR age_to IS NULL OR age_to = age_from THEN 1
+           ELSE 1 - abs(coalesce(age, 0) - (age_from + age_to) / 2)::double precision / (age_to - age_from)
+           END
+$$;
+-- +migrate StatementEnd
+
+-- overlap_share returns the share of shared ids relative to the shorter of the two lists of self_count
+-- and other_count ids.
+-- +migrate StatementBegin
+create function overlap_share(self_count bigint, other_count bigint, shared bigint) returns double precision
+    language sql
+    immutable
+as
+$$
+SELECT CASE
+           WHEN self_count = 0 OR other_count = 0 THEN 0
+           ELSE least(shared::double precision / least(self_count, other_count), 1)
+           END
+$$;
+-- +migrate StatementEnd
+
+-- match_score evaluates how well the candidate fits the user, the score is within [0, 1], the higher
+-- the better, matched_criteria names the criteria which contributed to it. Price, regions and age weigh
+-- 0.4, 0.3 and 0.3, stations, distance and soft lifestyle preferences weigh 0.2 each and are taken into
+-- account only when the user has them. overlapping are the regions overlapping the regions of the user,
+-- near are the stations close enough to its stations.
+-- +migrate StatementBegin
+create function match_score(self_uuid text, candidate_uuid text, overlapping bigint[], near bigint[])
+    returns table
+            (
+                score            double precision,
+                matched_criteria text[]
+            )
+    language sql
+    stable
+as
+$$
+WITH self AS (SELECT c.price_from,
+                     c.price_to,
+                     c.age_from,
+                     c.age_to,
+                     c.lat,
+                     c.lon,
+                     c.max_distance_km,
+                     c.smoking,
+                     c.smoking_importance,
+                     c.pets,
+                     c.pets_importance,
+                     c.sleep_schedule,
+                     c.sleep_schedule_importance,
+                     c.cleanliness,
+                     c.cleanliness_importance,
+                     c.guests,
+                     c.guests_importance,
+                     p.age
+              FROM search_criteria c
+                       JOIN personal p ON p.uuid = c.uuid
+              WHERE c.uuid = self_uuid),
+     other AS (SELECT c.price_from,
+                      c.price_to,
+                      c.age_from,
+                      c.age_to,
+                      c.lat,
+                      c.lon,
+                      p.age,
+                      p.smoking,
+                      p.pets,
+                      p.sleep_schedule,
+                      p.cleanliness,
+                      p.guests
+               FROM search_criteria c
+                        JOIN personal p ON p.uuid = c.uuid
+               WHERE c.uuid = candidate_uuid),
+     fit AS (SELECT ranges_overlap(self.price_from, self.price_to, other.price_from, other.price_to) AS price,
+                    overlap_share((SELECT count(*) FROM uuid_regions WHERE uuid = self_uuid),
+                                  (SELECT count(*) FROM uuid_regions WHERE uuid = candidate_uuid),
+                                  (SELECT count(*)
+                                   FROM uuid_regions
+                                   WHERE uuid = candidate_uuid
+                                     AND region_id = ANY (overlapping)))                            AS regions,
+                    EXISTS(SELECT 1 FROM uuid_stations WHERE uuid = self_uuid)                      AS by_stations,
+                    overlap_share((SELECT count(*) FROM uuid_stations WHERE uuid = self_uuid),
+                                  (SELECT count(*) FROM uuid_stations WHERE uuid = candidate_uuid),
+                                  (SELECT count(*)
+                                   FROM uuid_stations
+                                   WHERE uuid = candidate_uuid
+                                     AND station_id = ANY (near)))                                  AS stations,
+                    self.lat IS NOT NULL AND self.max_distance_km IS NOT NULL                      AS by_distance,
+                    CASE
+                        WHEN self.lat IS NULL OR self.max_distance_km IS NULL OR other.lat IS NULL THEN 0
+                        WHEN distance_km(self.lat, self.lon, other.lat, other.lon) > self.max_distance_km THEN 0
+                        ELSE 1 - distance_km(self.lat, self.lon, other.lat, other.lon) / self.max_distance_km / 2
+                        END                                                                         AS distance,
+                    age_fit(self.age_from, self.age_to, other.age)                                  AS forward,
+                    age_fit(other.age_from, other.age_to, self.age)                                 AS backward,
+                    (self.smoking_importance = 1)::int +
+                    (self.pets_importance = 1)::int +
+                    (self.sleep_schedule_importance = 1)::int +
+                    (self.cleanliness_importance = 1)::int +
+                    (self.guests_importance = 1)::int                                               AS preferred,
+                    (self.smoking_importance = 1 AND other.smoking = self.smoking)::int +
+                    (self.pets_importance = 1 AND other.pets = self.pets)::int +
+                    (self.sleep_schedule_importance = 1 AND other.sleep_schedule = self.sleep_schedule)::int +
+                    (self.cleanliness_importance = 1 AND other.cleanliness = self.cleanliness)::int +
+                    (self.guests_importance = 1 AND other.guests = self.guests)::int                AS satisfied
+             FROM self,
+                  other)
+SELECT round(((0.4 * price + 0.3 * regions + 0.2 * stations + 0.2 * distance +
+               CASE WHEN forward > 0 AND backward > 0 THEN 0.3 * (forward + backward) / 2 ELSE 0 END +
+               CASE WHEN preferred > 0 THEN 0.2 * satisfied / preferred ELSE 0 END) /
+              (1 + CASE WHEN by_stations THEN 0.2 ELSE 0 END +
+               CASE WHEN by_distance THEN 0.2 ELSE 0 END +
+               CASE WHEN preferred > 0 THEN 0.2 ELSE 0 END))::numeric, 2)::double precision,
+       array_remove(ARRAY [CASE WHEN price > 0 THEN 'price' END,
+                        CASE WHEN regions > 0 THEN 'regions' END,
+                        CASE WHEN stations > 0 THEN 'stations' END,
+                        CASE WHEN distance > 0 THEN 'distance' END,
+                        CASE WHEN forward > 0 AND backward > 0 THEN 'age' END,
+                        CASE WHEN satisfied > 0 THEN 'lifestyle' END], NULL)
+FROM fit
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+DROP FUNCTION match_score(text, text, bigint[], bigint[]);
+DROP FUNCTION overlap_share(bigint, bigint, bigint);
+DROP FUNCTION age_fit(numeric, numeric, smallint);
+DROP FUNCTION ranges_overlap(numeric, numeric, numeric, numeric);
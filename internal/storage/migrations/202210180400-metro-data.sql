@@ -0,0 +1,163 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- the metro dataset, later updates of it go into new migrations. They may move stations along a line,
+-- so positions are checked once the whole update is in. Stations are never deleted, as users may have
+-- chosen them.
+ALTER TABLE metro_stations DROP CONSTRAINT metro_stations_line_id_position_key;
+ALTER TABLE metro_stations
+    ADD CONSTRAINT metro_stations_line_id_position_key UNIQUE (line_id, position) DEFERRABLE INITIALLY DEFERRED;
+
+INSERT INTO metro_lines (id, name, color, circular) VALUES (1, 'Сокольническая', '#EF161E', false)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color, circular = excluded.circular;
+INSERT INTO metro_lines (id, name, color, circular) VALUES (2, 'Замоскворецкая', '#2DBE2C', false)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color, circular = excluded.circular;
+INSERT INTO metro_lines (id, name, color, circular) VALUES (5, 'Кольцевая', '#8D5B2D', true)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color, circular = excluded.circular;
+INSERT INTO metro_lines (id, name, color, circular) VALUES (6, 'Калужско-Рижская', '#ED9121', false)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color, circular = excluded.circular;
+INSERT INTO metro_lines (id, name, color, circular) VALUES (7, 'Таганско-Краснопресненская', '#800080', false)
+ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color, circular = excluded.circular;
+
+INSERT INTO metro_stations (id, line_id, position, name, region_id)
+VALUES
+       (101, 1, 1, 'Бульвар Рокоссовского', 4),
+       (102, 1, 2, 'Черкизовская', 4),
+       (103, 1, 3, 'Преображенская площадь', 4),
+       (104, 1, 4, 'Сокольники', 4),
+       (105, 1, 5, 'Красносельская', 1),
+       (106, 1, 6, 'Комсомольская', 1),
+       (107, 1, 7, 'Красные Ворота', 1),
+       (108, 1, 8, 'Чистые пруды', 1),
+       (109, 1, 9, 'Лубянка', 1),
+       (110, 1, 10, 'Охотный Ряд', 1),
+       (111, 1, 11, 'Библиотека имени Ленина', 1),
+       (112, 1, 12, 'Кропоткинская', 1),
+       (113, 1, 13, 'Парк культуры', 1),
+       (114, 1, 14, 'Фрунзенская', 1),
+       (115, 1, 15, 'Спортивная', 1),
+       (116, 1, 16, 'Воробьёвы горы', 7),
+       (117, 1, 17, 'Университет', 7),
+       (118, 1, 18, 'Проспект Вернадского', 8),
+       (119, 1, 19, 'Юго-Западная', 8),
+       (120, 1, 20, 'Тропарёво', 8),
+       (121, 1, 21, 'Румянцево', 12),
+       (122, 1, 22, 'Саларьево', 12),
+       (123, 1, 23, 'Филатов Луг', 12),
+       (124, 1, 24, 'Прокшино', 12),
+       (125, 1, 25, 'Ольховая', 12),
+       (126, 1, 26, 'Коммунарка', 12),
+       (201, 2, 1, 'Ховрино', 2),
+       (202, 2, 2, 'Беломорская', 2),
+       (203, 2, 3, 'Речной вокзал', 2),
+       (204, 2, 4, 'Водный стадион', 2),
+       (205, 2, 5, 'Войковская', 2),
+       (206, 2, 6, 'Сокол', 2),
+       (207, 2, 7, 'Аэропорт', 2),
+       (208, 2, 8, 'Динамо', 2),
+       (209, 2, 9, 'Белорусская', 1),
+       (210, 2, 10, 'Маяковская', 1),
+       (211, 2, 11, 'Тверская', 1),
+       (212, 2, 12, 'Театральная', 1),
+       (213, 2, 13, 'Новокузнецкая', 1),
+       (214, 2, 14, 'Павелецкая', 1),
+       (215, 2, 15, 'Автозаводская', 6),
+       (216, 2, 16, 'Технопарк', 6),
+       (217, 2, 17, 'Коломенская', 6),
+       (218, 2, 18, 'Каширская', 6),
+       (219, 2, 19, 'Кантемировская', 6),
+       (220, 2, 20, 'Царицыно', 6),
+       (221, 2, 21, 'Орехово', 6),
+       (222, 2, 22, 'Домодедовская', 6),
+       (223, 2, 23, 'Красногвардейская', 6),
+       (224, 2, 24, 'Алма-Атинская', 6),
+       (501, 5, 1, 'Комсомольская', 1),
+       (502, 5, 2, 'Курская', 1),
+       (503, 5, 3, 'Таганская', 1),
+       (504, 5, 4, 'Павелецкая', 1),
+       (505, 5, 5, 'Добрынинская', 1),
+       (506, 5, 6, 'Октябрьская', 1),
+       (507, 5, 7, 'Парк культуры', 1),
+       (508, 5, 8, 'Киевская', 8),
+       (509, 5, 9, 'Краснопресненская', 1),
+       (510, 5, 10, 'Белорусская', 1),
+       (511, 5, 11, 'Новослободская', 1),
+       (512, 5, 12, 'Проспект Мира', 1),
+       (601, 6, 1, 'Медведково', 3),
+       (602, 6, 2, 'Бабушкинская', 3),
+       (603, 6, 3, 'Свиблово', 3),
+       (604, 6, 4, 'Ботанический сад', 3),
+       (605, 6, 5, 'ВДНХ', 3),
+       (606, 6, 6, 'Алексеевская', 3),
+       (607, 6, 7, 'Рижская', 3),
+       (608, 6, 8, 'Проспект Мира', 1),
+       (609, 6, 9, 'Сухаревская', 1),
+       (610, 6, 10, 'Тургеневская', 1),
+       (611, 6, 11, 'Китай-город', 1),
+       (612, 6, 12, 'Третьяковская', 1),
+       (613, 6, 13, 'Октябрьская', 1),
+       (614, 6, 14, 'Шаболовская', 6),
+       (615, 6, 15, 'Ленинский проспект', 7),
+       (616, 6, 16, 'Академическая', 7),
+       (617, 6, 17, 'Профсоюзная', 7),
+       (618, 6, 18, 'Новые Черёмушки', 7),
+       (619, 6, 19, 'Калужская', 7),
+       (620, 6, 20, 'Беляево', 7),
+       (621, 6, 21, 'Коньково', 7),
+       (622, 6, 22, 'Тёплый Стан', 7),
+       (623, 6, 23, 'Ясенево', 7),
+       (624, 6, 24, 'Новоясеневская', 7),
+       (701, 7, 1, 'Планерная', 9),
+       (702, 7, 2, 'Сходненская', 9),
+       (703, 7, 3, 'Тушинская', 9),
+       (704, 7, 4, 'Спартак', 9),
+       (705, 7, 5, 'Щукинская', 9),
+       (706, 7, 6, 'Октябрьское Поле', 9),
+       (707, 7, 7, 'Полежаевская', 2),
+       (708, 7, 8, 'Беговая', 2),
+       (709, 7, 9, 'Улица 1905 года', 1),
+       (710, 7, 10, 'Баррикадная', 1),
+       (711, 7, 11, 'Пушкинская', 1),
+       (712, 7, 12, 'Кузнецкий Мост', 1),
+       (713, 7, 13, 'Китай-город', 1),
+       (714, 7, 14, 'Таганская', 1),
+       (715, 7, 15, 'Пролетарская', 5),
+       (716, 7, 16, 'Волгоградский проспект', 5),
+       (717, 7, 17, 'Текстильщики', 5),
+       (718, 7, 18, 'Кузьминки', 5),
+       (719, 7, 19, 'Рязанский проспект', 5),
+       (720, 7, 20, 'Выхино', 5),
+       (721, 7, 21, 'Лермонтовский проспект', 5),
+       (722, 7, 22, 'Жулебино', 5),
+       (723, 7, 23, 'Котельники', NULL)
+ON CONFLICT (id) DO UPDATE SET line_id   = excluded.line_id,
+                               position  = excluded.position,
+                               name      = excluded.name,
+                               region_id = excluded.region_id;
+
+INSERT INTO metro_transfers (station_id, other_id)
+VALUES
+       (106, 501),
+       (113, 507),
+       (214, 504),
+       (209, 510),
+       (608, 512),
+       (613, 506),
+       (110, 212),
+       (108, 610),
+       (109, 712),
+       (611, 713),
+       (503, 714),
+       (509, 710),
+       (211, 711),
+       (213, 612)
+ON CONFLICT DO NOTHING;
+
+-- +migrate Down
+
+-- the stations stay, users may have chosen them
+ALTER TABLE metro_stations DROP CONSTRAINT metro_stations_line_id_position_key;
+ALTER TABLE metro_stations
+    ADD CONSTRAINT metro_stations_line_id_position_key UNIQUE (line_id, position);
@@ -0,0 +1,202 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- match_score no longer grades the distance: the candidate is either within max_distance_km or not,
+-- otherwise the score and the distance criterion would let the user compute how far the candidate is.
+-- +migrate StatementBegin
+create or replace function match_score(self_uuid text, candidate_uuid text, overlapping bigint[], near bigint[])
+    returns table
+            (
+                score            double precision,
+                matched_criteria text[]
+            )
+    language sql
+    stable
+as
+$$
+WITH self AS (SELECT c.price_from,
+                     c.price_to,
+                     c.age_from,
+                     c.age_to,
+                     c.lat,
+                     c.lon,
+                     c.max_distance_km,
+                     c.smoking,
+                     c.smoking_importance,
+                     c.pets,
+                     c.pets_importance,
+                     c.sleep_schedule,
+                     c.sleep_schedule_importance,
+                     c.cleanliness,
+                     c.cleanliness_importance,
+                     c.guests,
+                     c.guests_importance,
+                     p.age
+              FROM search_criteria c
+                       JOIN personal p ON p.uuid = c.uuid
+              WHERE c.uuid = self_uuid),
+     other AS (SELECT c.price_from,
+                      c.price_to,
+                      c.age_from,
+                      c.age_to,
+                      c.lat,
+                      c.lon,
+                      p.age,
+                      p.smoking,
+                      p.pets,
+                      p.sleep_schedule,
+                      p.cleanliness,
+                      p.guests
+               FROM search_criteria c
+                        JOIN personal p ON p.uuid = c.uuid
+               WHERE c.uuid = candidate_uuid),
+     fit AS (SELECT ranges_overlap(self.price_from, self.price_to, other.price_from, other.price_to) AS price,
+                    overlap_share((SELECT count(*) FROM uuid_regions WHERE uuid = self_uuid),
+                                  (SELECT count(*) FROM uuid_regions WHERE uuid = candidate_uuid),
+                                  (SELECT count(*)
+                                   FROM uuid_regions
+                                   WHERE uuid = candidate_uuid
+                                     AND region_id = ANY (overlapping)))                            AS regions,
+                    EXISTS(SELECT 1 FROM uuid_stations WHERE uuid = self_uuid)                      AS by_stations,
+                    overlap_share((SELECT count(*) FROM uuid_stations WHERE uuid = self_uuid),
+                                  (SELECT count(*) FROM uuid_stations WHERE uuid = candidate_uuid),
+                                  (SELECT count(*)
+                                   FROM uuid_stations
+                                   WHERE uuid = candidate_uuid
+                                     AND station_id = ANY (near)))                                  AS stations,
+                    self.lat IS NOT NULL AND self.max_distance_km IS NOT NULL                      AS by_distance,
+                    CASE
+                        WHEN self.lat IS NULL OR self.max_distance_km IS NULL OR other.lat IS NULL THEN 0
+                        WHEN distance_km(self.lat, self.lon, other.lat, other.lon) > self.max_distance_km THEN 0
+                        ELSE 1
+                        END                                                                         AS distance,
+                    age_fit(self.age_from, self.age_to, other.age)                                  AS forward,
+                    age_fit(other.age_from, other.age_to, self.age)                                 AS backward,
+                    (self.smoking_importance = 1)::int +
+                    (self.pets_importance = 1)::int +
+                    (self.sleep_schedule_importance = 1)::int +
+                    (self.cleanliness_importance = 1)::int +
+                    (self.guests_importance = 1)::int                                               AS preferred,
+                    (self.smoking_importance = 1 AND other.smoking = self.smoking)::int +
+                    (self.pets_importance = 1 AND other.pets = self.pets)::int +
+                    (self.sleep_schedule_importance = 1 AND other.sleep_schedule = self.sleep_schedule)::int +
+                    (self.cleanliness_importance = 1 AND other.cleanliness = self.cleanliness)::int +
+                    (self.guests_importance = 1 AND other.guests = self.guests)::int                AS satisfied
+             FROM self,
+                  other)
+SELECT round(((0.4 * price + 0.3 * regions + 0.2 * stations + 0.2 * distance +
+               CASE WHEN forward > 0 AND backward > 0 THEN 0.3 * (forward + backward) / 2 ELSE 0 END +
+               CASE WHEN preferred > 0 THEN 0.2 * satisfied / preferred ELSE 0 END) /
+              (1 + CASE WHEN by_stations THEN 0.2 ELSE 0 END +
+               CASE WHEN by_distance THEN 0.2 ELSE 0 END +
+               CASE WHEN preferred > 0 THEN 0.2 ELSE 0 END))::numeric, 2)::double precision,
+       array_remove(ARRAY [CASE WHEN price > 0 THEN 'price' END,
+                        CASE WHEN regions > 0 THEN 'regions' END,
+                        CASE WHEN stations > 0 THEN 'stations' END,
+                        CASE WHEN distance > 0 THEN 'distance' END,
+                        CASE WHEN forward > 0 AND backward > 0 THEN 'age' END,
+                        CASE WHEN satisfied > 0 THEN 'lifestyle' END], NULL)
+FROM fit
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+-- +migrate StatementBegin
+create or replace function match_score(self_uuid text, candidate_uuid text, overlapping bigint[], near bigint[])
+    returns table
+            (
+                score            double precision,
+                matched_criteria text[]
+            )
+    language sql
+    stable
+as
+$$
+WITH self AS (SELECT c.price_from,
+                     c.price_to,
+                     c.age_from,
+                     c.age_to,
+                     c.lat,
+                     c.lon,
+                     c.max_distance_km,
+                     c.smoking,
+                     c.smoking_importance,
+                     c.pets,
+                     c.pets_importance,
+                     c.sleep_schedule,
+                     c.sleep_schedule_importance,
+                     c.cleanliness,
+                     c.cleanliness_importance,
+                     c.guests,
+                     c.guests_importance,
+                     p.age
+              FROM search_criteria c
+                       JOIN personal p ON p.uuid = c.uuid
+              WHERE c.uuid = self_uuid),
+     other AS (SELECT c.price_from,
+                      c.price_to,
+                      c.age_from,
+                      c.age_to,
+                      c.lat,
+                      c.lon,
+                      p.age,
+                      p.smoking,
+                      p.pets,
+                      p.sleep_schedule,
+                      p.cleanliness,
+                      p.guests
+               FROM search_criteria c
+                        JOIN personal p ON p.uuid = c.uuid
+               WHERE c.uuid = candidate_uuid),
+     fit AS (SELECT ranges_overlap(self.price_from, self.price_to, other.price_from, other.price_to) AS price,
+                    overlap_share((SELECT count(*) FROM uuid_regions WHERE uuid = self_uuid),
+                                  (SELECT count(*) FROM uuid_regions WHERE uuid = candidate_uuid),
+                                  (SELECT count(*)
+                                   FROM uuid_regions
+                                   WHERE uuid = candidate_uuid
+                                     AND region_id = ANY (overlapping)))                            AS regions,
+                    EXISTS(SELECT 1 FROM uuid_stations WHERE uuid = self_uuid)                      AS by_stations,
+                    overlap_share((SELECT count(*) FROM uuid_stations WHERE uuid = self_uuid),
+                                  (SELECT count(*) FROM uuid_stations WHERE uuid = candidate_uuid),
+                                  (SELECT count(*)
+                                   FROM uuid_stations
+                                   WHERE uuid = candidate_uuid
+                                     AND station_id = ANY (near)))                                  AS stations,
+                    self.lat IS NOT NULL AND self.max_distance_km IS NOT NULL                      AS by_distance,
+                    CASE
+                        WHEN self.lat IS NULL OR self.max_distance_km IS NULL OR other.lat IS NULL THEN 0
+                        WHEN distance_km(self.lat, self.lon, other.lat, other.lon) > self.max_distance_km THEN 0
+                        ELSE 1 - distance_km(self.lat, self.lon, other.lat, other.lon) / self.max_distance_km / 2
+                        END                                                                         AS distance,
+                    age_fit(self.age_from, self.age_to, other.age)                                  AS forward,
+                    age_fit(other.age_from, other.age_to, self.age)                                 AS backward,
+                    (self.smoking_importance = 1)::int +
+                    (self.pets_importance = 1)::int +
+                    (self.sleep_schedule_importance = 1)::int +
+                    (self.cleanliness_importance = 1)::int +
+                    (self.guests_importance = 1)::int                                               AS preferred,
+                    (self.smoking_importance = 1 AND other.smoking = self.smoking)::int +
+                    (self.pets_importance = 1 AND other.pets = self.pets)::int +
+                    (self.sleep_schedule_importance = 1 AND other.sleep_schedule = self.sleep_schedule)::int +
+                    (self.cleanliness_importance = 1 AND other.cleanliness = self.cleanliness)::int +
+                    (self.guests_importance = 1 AND other.guests = self.guests)::int                AS satisfied
+             FROM self,
+                  other)
+SELECT round(((0.4 * price + 0.3 * regions + 0.2 * stations + 0.2 * distance +
+               CASE WHEN forward > 0 AND backward > 0 THEN 0.3 * (forward + backward) / 2 ELSE 0 END +
+               CASE WHEN preferred > 0 THEN 0.2 * satisfied / preferred ELSE 0 END) /
+              (1 + CASE WHEN by_stations THEN 0.2 ELSE 0 END +
+               CASE WHEN by_distance THEN 0.2 ELSE 0 END +
+               CASE WHEN preferred > 0 THEN 0.2 ELSE 0 END))::numeric, 2)::double precision,
+       array_remove(ARRAY [CASE WHEN price > 0 THEN 'price' END,
+                        CASE WHEN regions > 0 THEN 'regions' END,
+                        CASE WHEN stations > 0 THEN 'stations' END,
+                        CASE WHEN distance > 0 THEN 'distance' END,
+                        CASE WHEN forward > 0 AND backward > 0 THEN 'age' END,
+                        CASE WHEN satisfied > 0 THEN 'lifestyle' END], NULL)
+FROM fit
+$$;
+-- +migrate StatementEnd
@@ -0,0 +1,61 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+DROP FUNCTION bounding_box(double precision, double precision, double precision);
+
+-- bounding_boxes returns (lon, lat) boxes containing all the points within km of the given one.
+-- Near the poles a single box spans all longitudes, a box crossing the antimeridian is split in two.
+-- +migrate StatementBegin
+create function bounding_boxes(lat double precision, lon double precision, km double precision) returns setof box
+    language sql
+    immutable
+as
+$$
+WITH span AS (SELECT degrees(km / 6371) AS dlat,
+                     CASE
+                         WHEN cos(radians(lat)) > sin(km / 6371)
+                             THEN degrees(asin(sin(km / 6371) / cos(radians(lat))))
+                         END AS dlon)
+SELECT box(point(-180, greatest(lat - dlat, -90)), point(180, least(lat + dlat, 90)))
+FROM span
+WHERE dlon IS NULL
+UNION ALL
+SELECT box(point(greatest(lon - dlon, -180), lat - dlat), point(least(lon + dlon, 180), lat + dlat))
+FROM span
+WHERE dlon IS NOT NULL
+UNION ALL
+-- the part beyond the antimeridian in the west
+SELECT box(point(lon - dlon + 360, lat - dlat), point(180, lat + dlat))
+FROM span
+WHERE lon - dlon < -180
+UNION ALL
+-- the part beyond the antimeridian in the east
+SELECT box(point(-180, lat - dlat), point(lon + dlon - 360, lat + dlat))
+FROM span
+WHERE lon + dlon > 180
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+DROP FUNCTION bounding_boxes(double precision, double precision, double precision);
+
+-- bounding_box returns a (lon, lat) box containing all the points within km of the given one.
+-- Near the poles it spans all longitudes, it isn't split at the antimeridian.
+-- +migrate StatementBegin
+create function bounding_box(lat double precision, lon double precision, km double precision) returns box
+    language sql
+    immutable
+as
+$$
+SELECT CASE
+           WHEN cos(radians(lat)) <= sin(km / 6371)
+               THEN box(point(-180, greatest(lat - degrees(km / 6371), -90)),
+                        point(180, least(lat + degrees(km / 6371), 90)))
+           ELSE box(point(lon - degrees(asin(sin(km / 6371) / cos(radians(lat)))), lat - degrees(km / 6371)),
+                    point(lon + degrees(asin(sin(km / 6371) / cos(radians(lat)))), lat + degrees(km / 6371)))
+           END
+$$;
+-- +migrate StatementEnd
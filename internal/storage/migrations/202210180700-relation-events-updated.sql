@@ -0,0 +1,13 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- when the replaced relation was made, undo restores it so that its TTL doesn't start over
+alter table relation_events
+    add column previous_updated timestamp;
+
+-- +migrate Down
+
+alter table relation_events
+    drop column previous_updated;
@@ -0,0 +1,26 @@
+-- noinspection SqlNoDataSourceInspectionForFile
+
+
+-- +migrate Up
+
+-- photos are looked up by the key in the URL to check who may see them
+create index photos_key_idx on photos (key);
+create index photos_thumbnail_key_idx on photos (thumbnail_key);
+
+-- photos are served to signed in users only
+UPDATE photos
+SET url           = replace(url, '/static/photos/', '/public/v1/photos/files/'),
+    thumbnail_url = replace(thumbnail_url, '/static/photos/', '/public/v1/photos/files/');
+UPDATE personal
+SET avatar_link = replace(avatar_link, '/static/photos/', '/public/v1/photos/files/');
+
+-- +migrate Down
+
+UPDATE personal
+SET avatar_link = replace(avatar_link, '/public/v1/photos/files/', '/static/photos/');
+UPDATE photos
+SET url           = replace(url, '/public/v1/photos/files/', '/static/photos/'),
+    thumbnail_url = replace(thumbnail_url, '/public/v1/photos/files/', '/static/photos/');
+
+DROP INDEX photos_thumbnail_key_idx;
+DROP INDEX photos_key_idx;
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+const reportColumns = `id, uuid, target, reason, comment, created, resolution, note, resolved`
+
+// Block records that uuid blocked target, both users must have live configs.
+func (s *Storage) Block(ctx context.Context, uuid, target string) error {
+	res, err := s.db.Exec(ctx, `
+INSERT INTO blocks (uuid, target, created)
+SELECT $1, $2, now()
+WHERE (SELECT count(*) FROM config WHERE uuid IN ($1, $2) AND deleted IS NULL) = 2
+ON CONFLICT (uuid, target) DO UPDATE SET created = blocks.created
+`, uuid, target)
+	if err != nil {
+		return fmt.Errorf("err inserting block of %s by %s: %w", target, uuid, err)
+	}
+	if res.RowsAffected() == 0 {
+		return common.ErrConfigNotFound
+	}
+	return nil
+}
+
+// Report puts the report into the moderation queue and sets its ID and creation time.
+func (s *Storage) Report(ctx context.Context, report *models.Report) error {
+	row := s.db.QueryRow(ctx, `
+INSERT INTO reports (uuid, target, reason, comment, created)
+SELECT $1, $2, $3, $4, $5
+WHERE (SELECT count(*) FROM config WHERE uuid IN ($1, $2) AND deleted IS NULL) = 2
+RETURNING id, created`, report.UUID, report.Target, report.Reason, report.Comment, time.Now())
+	err := row.Scan(&report.ID, &report.Created)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return common.ErrConfigNotFound
+	default:
+		return fmt.Errorf("err inserting report of %s by %s: %w", report.Target, report.UUID, err)
+	}
+	return nil
+}
+
+// ListReports returns unresolved reports, the oldest first.
+func (s *Storage) ListReports(ctx context.Context, limit, offset int64) ([]*models.Report, error) {
+	var reports []*models.Report
+	err := pgxscan.Select(ctx, s.db, &reports, `
+SELECT `+reportColumns+`
+FROM reports
+WHERE resolved IS NULL
+ORDER BY created, id
+LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("err selecting reports: %w", err)
+	}
+	return reports, nil
+}
+
+// ResolveReport takes the report out of the queue, it returns common.ErrReportNotFound
+// if there is no unresolved report with the id.
+func (s *Storage) ResolveReport(ctx context.Context, id int64, resolution models.Resolution, note string, entry *models.AuditEntry) (*models.Report, error) { //nolint:lll
+	var report models.Report
+	err := s.audited(ctx, entry, func(tx pgx.Tx) error {
+		err := pgxscan.Get(ctx, tx, &report, `
+UPDATE reports
+SET resolution = $2,
+    note       = $3,
+    resolved   = now()
+WHERE id = $1
+  AND resolved IS NULL
+RETURNING `+reportColumns, id, resolution, note)
+		switch {
+		case err == nil:
+		case errors.Is(err, pgx.ErrNoRows):
+			return common.ErrReportNotFound
+		default:
+			return fmt.Errorf("err resolving report %d: %w", id, err)
+		}
+		entry.Target = strconv.FormatInt(id, 10)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/gerladeno/homie-core/internal/models"
+)
+
+// keysetRow is an item of a list paginated by (timestamp, uuid) in descending order.
+type keysetRow struct {
+	UUID      string    `db:"uuid"`
+	Timestamp time.Time `db:"ts"`
+}
+
+// scoredRow is a match ranked by its score. Count is the total number of matches, it is returned
+// with an empty row if the page is empty.
+type scoredRow struct {
+	Count           int64    `db:"count"`
+	UUID            *string  `db:"uuid"`
+	Score           *float64 `db:"score"`
+	MatchedCriteria []string `db:"matched_criteria"`
+}
+
+// fetchLimit asks for one extra row to find out whether there is a next page, nil means no limit.
+func fetchLimit(limit int64) *int64 {
+	if limit <= 0 {
+		return nil
+	}
+	fetch := limit + 1
+	return &fetch
+}
+
+func afterTimestamp(c *models.Cursor) *time.Time {
+	if c == nil {
+		return nil
+	}
+	return &c.Timestamp
+}
+
+func afterScore(c *models.Cursor) *float64 {
+	if c == nil {
+		return nil
+	}
+	return &c.Score
+}
+
+func afterUUID(c *models.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	return c.UUID
+}
+
+// nextPage cuts the extra row off and sets the cursor of the next page if there is one.
+func nextPage(rows []keysetRow, limit int64, page *models.Page) []string {
+	if limit > 0 && int64(len(rows)) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = (&models.Cursor{Timestamp: last.Timestamp, UUID: last.UUID}).Encode()
+	}
+	uuids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		uuids = append(uuids, row.UUID)
+	}
+	return uuids
+}
+
+// nextScoredPage works like nextPage for matches, it also returns the rows of the matches by their uuids.
+func nextScoredPage(rows []scoredRow, limit int64, page *models.Page) ([]string, map[string]scoredRow) {
+	if len(rows) > 0 {
+		page.Count = rows[0].Count
+	}
+	if len(rows) == 1 && rows[0].UUID == nil {
+		return nil, nil
+	}
+	if limit > 0 && int64(len(rows)) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = (&models.Cursor{Score: *last.Score, UUID: *last.UUID}).Encode()
+	}
+	uuids := make([]string, 0, len(rows))
+	scores := make(map[string]scoredRow, len(rows))
+	for _, row := range rows {
+		uuids = append(uuids, *row.UUID)
+		scores[*row.UUID] = row
+	}
+	return uuids, scores
+}
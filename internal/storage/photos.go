@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+const photoColumns = `id, uuid, position, url, thumbnail_url, key, thumbnail_key, created`
+
+// AddPhoto appends the photo to the end of the gallery of its owner unless the gallery already has limit photos.
+func (s *Storage) AddPhoto(ctx context.Context, photo *models.Photo, limit int) error {
+	err := s.inPhotosTx(ctx, photo.UUID, func(tx pgx.Tx) error {
+		var count int
+		if err := tx.QueryRow(ctx, `SELECT count(*) FROM photos WHERE uuid = $1`, photo.UUID).Scan(&count); err != nil {
+			return fmt.Errorf("err counting photos: %w", err)
+		}
+		if count >= limit {
+			return common.ErrTooManyPhotos
+		}
+		row := tx.QueryRow(ctx, `
+INSERT INTO photos (uuid, position, key, thumbnail_key, url, thumbnail_url)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, position, created
+`, photo.UUID, count, photo.Key, photo.ThumbnailKey, photo.URL, photo.ThumbnailURL)
+		if err := row.Scan(&photo.ID, &photo.Position, &photo.Created); err != nil {
+			return fmt.Errorf("err inserting photo: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("err adding photo for %s: %w", photo.UUID, err)
+	}
+	return nil
+}
+
+func (s *Storage) ListPhotos(ctx context.Context, uuid string) ([]*models.Photo, error) {
+	var photos []*models.Photo
+	err := pgxscan.Select(ctx, s.db, &photos,
+		`SELECT `+photoColumns+` FROM photos WHERE uuid = $1 ORDER BY position`, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("err listing photos of %s: %w", uuid, err)
+	}
+	return photos, nil
+}
+
+// DeletePhoto removes the photo from the gallery and returns it, so that its files can be deleted.
+func (s *Storage) DeletePhoto(ctx context.Context, uuid string, id int64) (*models.Photo, error) {
+	var photo models.Photo
+	err := s.inPhotosTx(ctx, uuid, func(tx pgx.Tx) error {
+		err := pgxscan.Get(ctx, tx, &photo,
+			`DELETE FROM photos WHERE id = $1 AND uuid = $2 RETURNING `+photoColumns, id, uuid)
+		switch {
+		case err == nil:
+		case errors.Is(err, pgx.ErrNoRows):
+			return common.ErrPhotoNotFound
+		default:
+			return fmt.Errorf("err deleting photo %d: %w", id, err)
+		}
+		_, err = tx.Exec(ctx, `UPDATE photos SET position = position - 1 WHERE uuid = $1 AND position > $2`,
+			uuid, photo.Position)
+		if err != nil {
+			return fmt.Errorf("err moving photos: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("err deleting photo of %s: %w", uuid, err)
+	}
+	return &photo, nil
+}
+
+// ReorderPhotos puts the photos of uuid in the order of ids, which must list every photo of the gallery once.
+func (s *Storage) ReorderPhotos(ctx context.Context, uuid string, ids []int64) error {
+	err := s.inPhotosTx(ctx, uuid, func(tx pgx.Tx) error {
+		res, err := tx.Exec(ctx, `
+UPDATE photos
+SET position = ordered.position - 1
+FROM unnest($2::bigint[]) WITH ORDINALITY AS ordered(id, position)
+WHERE photos.id = ordered.id
+  AND photos.uuid = $1
+`, uuid, ids)
+		if err != nil {
+			return fmt.Errorf("err reordering photos: %w", err)
+		}
+		var count int64
+		if err = tx.QueryRow(ctx, `SELECT count(*) FROM photos WHERE uuid = $1`, uuid).Scan(&count); err != nil {
+			return fmt.Errorf("err counting photos: %w", err)
+		}
+		if res.RowsAffected() != int64(len(ids)) || count != int64(len(ids)) {
+			return common.ErrInvalidPhotoOrder
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("err reordering photos of %s: %w", uuid, err)
+	}
+	return nil
+}
+
+// PhotoVisible reports whether viewer may see the photo or thumbnail stored under key: its own photos and
+// photos of live accounts shown in matches unless one of them blocked the other.
+func (s *Storage) PhotoVisible(ctx context.Context, viewer, key string) (bool, error) {
+	var visible bool
+	err := s.db.QueryRow(ctx, `
+SELECT EXISTS(SELECT 1
+              FROM photos p
+                       JOIN config c ON c.uuid = p.uuid AND c.deleted IS NULL
+              WHERE (p.key = $2 OR p.thumbnail_key = $2)
+                AND (p.uuid = $1 OR shown(p.uuid) AND NOT blocked(p.uuid, $1)))`, viewer, key).Scan(&visible)
+	if err != nil {
+		return false, fmt.Errorf("err checking photo %s for %s: %w", key, viewer, err)
+	}
+	return visible, nil
+}
+
+// inPhotosTx runs fn in a transaction holding the lock on the config of uuid, so that gallery changes
+// don't interleave, and then makes the first photo of the gallery the avatar.
+func (s *Storage) inPhotosTx(ctx context.Context, uuid string, fn func(tx pgx.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Warnf("err rolling back tx during changing photos: %v", err)
+		}
+	}()
+	var locked string
+	err = tx.QueryRow(ctx, `SELECT uuid FROM config WHERE uuid = $1 AND deleted IS NULL FOR UPDATE`, uuid).Scan(&locked)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return common.ErrConfigNotFound
+	default:
+		return fmt.Errorf("err locking config: %w", err)
+	}
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = refreshAvatar(ctx, tx, uuid); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// refreshAvatar makes the first photo of the gallery of uuid its avatar.
+func refreshAvatar(ctx context.Context, tx pgx.Tx, uuid string) error {
+	_, err := tx.Exec(ctx, `
+UPDATE personal
+SET avatar_link = coalesce((SELECT url FROM photos WHERE uuid = $1 ORDER BY position LIMIT 1), '')
+WHERE uuid = $1
+`, uuid)
+	if err != nil {
+		return fmt.Errorf("err updating avatar: %w", err)
+	}
+	return nil
+}
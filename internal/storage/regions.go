@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+const regionColumns = `id, parent_id, name, description, boundary, deleted_at`
+
+// GetRegions returns the regions users can choose, deleted ones are skipped.
+func (s *Storage) GetRegions(ctx context.Context) ([]*models.Region, error) {
+	var regions []*models.Region
+	err := pgxscan.Select(ctx, s.db, &regions,
+		`SELECT `+regionColumns+` FROM regions WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("err getting regions: %w", err)
+	}
+	return regions, nil
+}
+
+// ListAllRegions returns all regions including deleted ones.
+func (s *Storage) ListAllRegions(ctx context.Context) ([]*models.Region, error) {
+	var regions []*models.Region
+	err := pgxscan.Select(ctx, s.db, &regions, `SELECT `+regionColumns+` FROM regions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("err listing regions: %w", err)
+	}
+	return regions, nil
+}
+
+func (s *Storage) GetRegion(ctx context.Context, id int64) (*models.Region, error) {
+	var region models.Region
+	err := pgxscan.Get(ctx, s.db, &region, `SELECT `+regionColumns+` FROM regions WHERE id = $1`, id)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, common.ErrRegionNotFound
+	default:
+		return nil, fmt.Errorf("err getting region %d: %w", id, err)
+	}
+	return &region, nil
+}
+
+// CreateRegion adds the region and sets its ID, the parent must be an existing region.
+func (s *Storage) CreateRegion(ctx context.Context, region *models.Region, entry *models.AuditEntry) error {
+	return s.audited(ctx, entry, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, `
+INSERT INTO regions (parent_id, name, description, boundary, created_at, updated_at)
+SELECT $1, $2, $3, $4, now(), now()
+WHERE $1::bigint IS NULL
+   OR EXISTS(SELECT 1 FROM regions WHERE id = $1 AND deleted_at IS NULL)
+RETURNING id`, region.ParentID, region.Name, region.Description, region.Boundary)
+		err := row.Scan(&region.ID)
+		switch {
+		case err == nil:
+		case errors.Is(err, pgx.ErrNoRows):
+			return common.ErrInvalidRegionParent
+		default:
+			return fmt.Errorf("err inserting region: %w", err)
+		}
+		entry.Target = strconv.FormatInt(region.ID, 10)
+		return nil
+	})
+}
+
+// UpdateRegion changes the region, the parent must be an existing region outside of its subtree.
+func (s *Storage) UpdateRegion(ctx context.Context, region *models.Region, entry *models.AuditEntry) error {
+	return s.audited(ctx, entry, func(tx pgx.Tx) error {
+		res, err := tx.Exec(ctx, `
+UPDATE regions
+SET parent_id   = $2,
+    name        = $3,
+    description = $4,
+    boundary    = $5,
+    updated_at  = now()
+WHERE id = $1
+  AND deleted_at IS NULL`, region.ID, region.ParentID, region.Name, region.Description, region.Boundary)
+		if err != nil {
+			return fmt.Errorf("err updating region %d: %w", region.ID, err)
+		}
+		if res.RowsAffected() == 0 {
+			return common.ErrRegionNotFound
+		}
+		var valid bool
+		row := tx.QueryRow(ctx, `
+WITH RECURSIVE subtree AS (SELECT id
+                           FROM regions
+                           WHERE id = $1
+                           UNION
+                           SELECT r.id
+                           FROM regions r
+                                    JOIN subtree ON r.parent_id = subtree.id)
+SELECT $2::bigint IS NULL
+           OR EXISTS(SELECT 1
+                     FROM regions
+                     WHERE id = $2
+                       AND deleted_at IS NULL
+                       AND id NOT IN (SELECT id FROM subtree))`, region.ID, region.ParentID)
+		if err = row.Scan(&valid); err != nil {
+			return fmt.Errorf("err checking parent of region %d: %w", region.ID, err)
+		}
+		if !valid {
+			return common.ErrInvalidRegionParent
+		}
+		entry.Target = strconv.FormatInt(region.ID, 10)
+		return nil
+	})
+}
+
+// DeleteRegion marks the region and all regions inside it deleted. Users keep them in their criteria,
+// but deleted regions match nobody and can't be chosen anymore.
+func (s *Storage) DeleteRegion(ctx context.Context, id int64, entry *models.AuditEntry) error {
+	return s.audited(ctx, entry, func(tx pgx.Tx) error {
+		res, err := tx.Exec(ctx, `
+WITH RECURSIVE subtree AS (SELECT id
+                           FROM regions
+                           WHERE id = $1
+                             AND deleted_at IS NULL
+                           UNION
+                           SELECT r.id
+                           FROM regions r
+                                    JOIN subtree ON r.parent_id = subtree.id
+                           WHERE r.deleted_at IS NULL)
+UPDATE regions
+SET deleted_at = now(),
+    updated_at = now()
+WHERE id IN (SELECT id FROM subtree)`, id)
+		if err != nil {
+			return fmt.Errorf("err deleting region %d: %w", id, err)
+		}
+		if res.RowsAffected() == 0 {
+			return common.ErrRegionNotFound
+		}
+		entry.Target = strconv.FormatInt(id, 10)
+		return nil
+	})
+}
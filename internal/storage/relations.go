@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/common"
+	"github.com/jackc/pgx/v4"
+)
+
+// recordRelationEvent stores the relation change together with the relation it replaced and the time
+// that one was made, previous is nil if there was none.
+func (s *Storage) recordRelationEvent(ctx context.Context, tx pgx.Tx, relation *models.Relation, previous *int8,
+	previousUpdated *time.Time) error {
+	_, err := tx.Exec(ctx, `
+INSERT INTO relation_events (uuid, target, relation, previous, previous_updated, created)
+VALUES ($1, $2, $3, $4, $5, $6)
+`, relation.UUID, relation.Target, relation.Relation, previous, previousUpdated, time.Now())
+	if err != nil {
+		return fmt.Errorf("err inserting relation event for %s and %s: %w", relation.UUID, relation.Target, err)
+	}
+	return nil
+}
+
+// UndoRelation reverts the latest relation change made by uuid after since. The previous relation is restored
+// with the time it was made, so its TTL doesn't start over, or removed if there was none, the mutual match
+// is updated accordingly. It returns the restored relation, Neither if the relation was removed.
+func (s *Storage) UndoRelation(ctx context.Context, uuid string, since time.Time) (*models.Relation, error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("err undoing relation: %w", err)
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Warnf("err rolling back tx during undoing relation: %v", err)
+		}
+	}()
+	var (
+		id              int64
+		target          string
+		previous        *int8
+		previousUpdated *time.Time
+	)
+	row := tx.QueryRow(ctx, `
+SELECT id, target, previous, previous_updated
+FROM relation_events
+WHERE uuid = $1
+  AND NOT undone
+  AND created >= $2
+ORDER BY created DESC, id DESC
+LIMIT 1
+FOR UPDATE`, uuid, since)
+	err = row.Scan(&id, &target, &previous, &previousUpdated)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, common.ErrNothingToUndo
+	default:
+		return nil, fmt.Errorf("err selecting last relation event of %s: %w", uuid, err)
+	}
+	if err = lockPair(ctx, tx, uuid, target); err != nil {
+		return nil, fmt.Errorf("err undoing relation: %w", err)
+	}
+	relation := models.Relation{UUID: uuid, Target: target, Relation: int8(Neither)}
+	if previous == nil {
+		_, err = tx.Exec(ctx, `DELETE FROM relations WHERE uuid = $1 AND target = $2`, uuid, target)
+	} else {
+		relation.Relation = *previous
+		// events recorded before previous_updated was added don't know it
+		_, err = tx.Exec(ctx, `
+UPDATE relations
+SET relation = $3,
+    updated  = COALESCE($4::timestamp, now())
+WHERE uuid = $1
+  AND target = $2`, uuid, target, relation.Relation, previousUpdated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err restoring relation of %s and %s: %w", uuid, target, err)
+	}
+	if _, err = s.syncMutualMatch(ctx, tx, &relation); err != nil {
+		return nil, fmt.Errorf("err undoing relation: %w", err)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE relation_events SET undone = true WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("err marking relation event %d undone: %w", id, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("err committing undo relation transaction: %w", err)
+	}
+	return &relation, nil
+}
+
+// ListRelations returns relations of uuid to others and of others to uuid.
+func (s *Storage) ListRelations(ctx context.Context, uuid string) ([]*models.Relation, error) {
+	var relations []*models.Relation
+	err := pgxscan.Select(ctx, s.db, &relations, `
+SELECT uuid, target, relation
+FROM relations
+WHERE uuid = $1
+   OR target = $1
+ORDER BY updated`, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("err listing relations of %s: %w", uuid, err)
+	}
+	return relations, nil
+}
+
+// IsMutualLike reports whether both users liked or super liked each other and neither has blocked the other.
+func (s *Storage) IsMutualLike(ctx context.Context, uuid1, uuid2 string) (bool, error) {
+	var count int64
+	row := s.db.QueryRow(ctx, `
+SELECT count(*)
+FROM relations
+WHERE ((uuid = $1 AND target = $2) OR (uuid = $2 AND target = $1))
+  AND relation IN ($3, $4)
+  AND NOT blocked($1, $2)`, uuid1, uuid2, Liked, SuperLiked)
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("err checking mutual like for %s and %s: %w", uuid1, uuid2, err)
+	}
+	return uuid1 != uuid2 && count == 2, nil
+}
@@ -33,18 +33,38 @@ const (
 )
 
 type Storage struct {
-	log     *logrus.Entry
-	db      *pgxpool.Pool
-	dsn     string
-	metrics *metrics.DBClient
+	log          *logrus.Entry
+	db           *pgxpool.Pool
+	dsn          string
+	metrics      *metrics.DBClient
+	relationTTLs map[Relation]time.Duration
+	stationStops int
 }
 
-func New(ctx context.Context, log *logrus.Logger, dsn string) (*Storage, error) {
+type Option func(s *Storage)
+
+// WithRelationTTL makes relations of the given type expire after ttl: once the target changes
+// its personal data or search criteria, it is shown in matches again.
+func WithRelationTTL(relation Relation, ttl time.Duration) Option {
+	return func(s *Storage) {
+		s.relationTTLs[relation] = ttl
+	}
+}
+
+func New(ctx context.Context, log *logrus.Logger, dsn string, opts ...Option) (*Storage, error) {
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
-	s := Storage{log: log.WithField("module", "storage"), dsn: dsn}
+	s := Storage{
+		log:          log.WithField("module", "storage"),
+		dsn:          dsn,
+		relationTTLs: make(map[Relation]time.Duration),
+		stationStops: DefaultStationStops,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
 	s.db, err = pgxpool.ConnectConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("err connecting to postgres: %w", err)
@@ -57,6 +77,11 @@ func New(ctx context.Context, log *logrus.Logger, dsn string) (*Storage, error)
 	return &s, nil
 }
 
+// Close closes all the connections of the pool.
+func (s *Storage) Close() {
+	s.db.Close()
+}
+
 func (s *Storage) Exec(ctx context.Context, query string) error {
 	if _, err := s.db.Exec(ctx, query); err != nil {
 		return fmt.Errorf("err executing %s: %w", query, err)
@@ -107,7 +132,8 @@ func (s *Storage) Migrate() error {
 	return err
 }
 
-func (s *Storage) SaveConfig(ctx context.Context, config *models.Config) error {
+// SaveConfig stores the parts of config covered by mask, a nil mask replaces the whole config.
+func (s *Storage) SaveConfig(ctx context.Context, config *models.Config, mask models.FieldMask) error {
 	if config == nil {
 		return nil
 	}
@@ -120,56 +146,195 @@ func (s *Storage) SaveConfig(ctx context.Context, config *models.Config) error {
 			s.log.Warnf("err rolling back tx during saving config: %v", err)
 		}
 	}()
-	if err = s.upsertConfig(ctx, tx, config); err != nil {
-		return fmt.Errorf("err saving config: %w", err)
-	}
-	if err = s.upsertSettings(ctx, tx, config.Settings); err != nil {
+	if err = s.saveConfig(ctx, tx, config, mask); err != nil {
 		return fmt.Errorf("err saving config: %w", err)
 	}
-	if err = s.upsertPersonal(ctx, tx, config.Personal); err != nil {
-		return fmt.Errorf("err saving config: %w", err)
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("err committing save config transaction: %w", err)
 	}
-	if err = s.upsertCriteria(ctx, tx, config.Criteria); err != nil {
-		return fmt.Errorf("err saving config: %w", err)
+	return nil
+}
+
+// PatchConfig loads the config of uuid, passes it to patch and saves the fields covered by the returned
+// mask within one transaction, the config row is locked meanwhile so concurrent patches don't overwrite
+// each other. A missing config is patched as an empty one.
+func (s *Storage) PatchConfig(ctx context.Context, uuid string, patch func(config *models.Config) (*models.Config, models.FieldMask, error)) (*models.Config, error) { //nolint:lll
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("err patching config: %w", err)
 	}
-	if config.Criteria != nil && config.Criteria.Regions != nil {
-		if err = s.updateCriteriaRegions(ctx, tx, config.Criteria.UUID, config.Criteria.Regions); err != nil {
-			return fmt.Errorf("err saving config: %w", err)
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Warnf("err rolling back tx during patching config: %v", err)
 		}
+	}()
+	var locked string
+	err = tx.QueryRow(ctx, `SELECT uuid FROM config WHERE uuid = $1 FOR UPDATE`, uuid).Scan(&locked)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("err locking config of %s: %w", uuid, err)
+	}
+	config, err := s.loadConfig(ctx, tx, uuid)
+	switch {
+	case err == nil:
+	case errors.Is(err, common.ErrConfigNotFound):
+		config = &models.Config{UUID: uuid}
+	default:
+		return nil, fmt.Errorf("err getting config to patch: %w", err)
+	}
+	config, mask, err := patch(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.saveConfig(ctx, tx, config, mask); err != nil {
+		return nil, fmt.Errorf("err saving patched config: %w", err)
 	}
 	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("err committing save config transaction: %w", err)
+		return nil, fmt.Errorf("err committing patch config transaction: %w", err)
+	}
+	return config, nil
+}
+
+func (s *Storage) saveConfig(ctx context.Context, tx pgx.Tx, config *models.Config, mask models.FieldMask) error {
+	if err := s.upsertConfig(ctx, tx, config, mask); err != nil {
+		return err
+	}
+	if err := s.upsertSettings(ctx, tx, config.Settings, mask); err != nil {
+		return err
+	}
+	if err := s.upsertPersonal(ctx, tx, config.Personal, mask); err != nil {
+		return err
+	}
+	if err := s.upsertCriteria(ctx, tx, config.Criteria, mask); err != nil {
+		return err
+	}
+	if err := s.deleteRemoved(ctx, tx, config, mask); err != nil {
+		return err
+	}
+	// a PUT without regions or stations keeps the stored ones, while a patch setting them to null clears them
+	criteria := config.Criteria
+	if criteria != nil && (criteria.Regions != nil || mask != nil) && mask.Has("criteria.regions") {
+		if err := s.updateCriteriaIDs(ctx, tx, "uuid_regions", "region_id", criteria.UUID, criteria.Regions); err != nil {
+			return err
+		}
+	}
+	if criteria != nil && (criteria.Stations != nil || mask != nil) && mask.Has("criteria.stations") {
+		if err := s.updateCriteriaIDs(ctx, tx, "uuid_stations", "station_id", criteria.UUID, criteria.Stations); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (s *Storage) upsertConfig(ctx context.Context, tx pgx.Tx, config *models.Config) error {
+// deleteRemoved deletes the sections of config a patch set to null. Sections missing from a PUT,
+// which comes with a nil mask, are kept.
+func (s *Storage) deleteRemoved(ctx context.Context, tx pgx.Tx, config *models.Config, mask models.FieldMask) error {
+	if mask == nil {
+		return nil
+	}
+	var queries []string
+	if config.Settings == nil && mask.Has("settings") {
+		queries = append(queries, `DELETE FROM settings WHERE uuid = $1`)
+	}
+	if config.Personal == nil && mask.Has("personal") {
+		queries = append(queries, `DELETE FROM personal WHERE uuid = $1`)
+	}
+	if config.Criteria == nil && mask.Has("criteria") {
+		queries = append(queries,
+			`DELETE FROM uuid_regions WHERE uuid = $1`,
+			`DELETE FROM uuid_stations WHERE uuid = $1`,
+			`DELETE FROM search_criteria WHERE uuid = $1`,
+		)
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query, config.UUID); err != nil {
+			return fmt.Errorf("err deleting removed config data of %s: %w", config.UUID, err)
+		}
+	}
+	return nil
+}
+
+// column binds a table column to the JSON path of the config field stored in it. Read-only columns
+// are written only when the row is inserted, their values are maintained elsewhere.
+type column struct {
+	name     string
+	path     string
+	value    interface{}
+	readOnly bool
+}
+
+// upsertQuery builds an insert of all the columns of a row keyed by uuid, which on conflict updates only
+// the columns covered by mask. If touch is set, the updated column is bumped when any of them changes.
+// It returns false if mask covers none of the columns.
+func upsertQuery(table, uuid string, columns []column, mask models.FieldMask, touch bool) (string, []interface{}, bool) {
+	names := []string{"uuid"}
+	placeholders := []string{"$1"}
+	args := []interface{}{uuid}
+	var set, current, excluded []string
+	for i, c := range columns {
+		names = append(names, c.name)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
+		args = append(args, c.value)
+		if c.readOnly || !mask.Has(c.path) {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%[1]s = excluded.%[1]s", c.name))
+		current = append(current, table+"."+c.name)
+		excluded = append(excluded, "excluded."+c.name)
+	}
+	if len(set) == 0 {
+		return "", nil, false
+	}
+	if touch {
+		set = append(set, fmt.Sprintf("updated = CASE WHEN (%s) IS DISTINCT FROM (%s) THEN now() ELSE %s.updated END",
+			strings.Join(current, ", "), strings.Join(excluded, ", "), table))
+	}
+	query := fmt.Sprintf(`
+INSERT INTO %s (%s)
+VALUES (%s)
+ON CONFLICT (uuid) DO UPDATE SET %s
+`, table, strings.Join(names, ", "), strings.Join(placeholders, ", "), strings.Join(set, ",\n\t"))
+	return query, args, true
+}
+
+func (s *Storage) upsertConfig(ctx context.Context, tx pgx.Tx, config *models.Config, mask models.FieldMask) error {
 	query := `
-INSERT INTO config (uuid, created, updated)
-VALUES ($1, $2, $3)
-ON CONFLICT (uuid) DO UPDATE SET updated = EXCLUDED.updated
+INSERT INTO config (uuid, role, created, updated)
+VALUES ($1, COALESCE($2::smallint, $5), $3, $4)
+ON CONFLICT (uuid) DO UPDATE SET role = COALESCE($2::smallint, config.role),
+                                 updated = EXCLUDED.updated
+WHERE config.deleted IS NULL
 `
+	// the role is kept unless it is sent explicitly
+	var role *models.Role
+	if mask.Has("role") {
+		role = config.Role
+	}
 	t := time.Now()
-	res, err := tx.Exec(ctx, query, config.UUID, t, t)
+	res, err := tx.Exec(ctx, query, config.UUID, role, t, t, models.Seeker)
 	if err != nil {
 		return fmt.Errorf("err inserting config for %s: %w", config.UUID, err)
 	}
 	if res.RowsAffected() == 0 {
-		return errors.New("err no rows affected while upserting config")
+		// the config is a tombstone of a deleted account
+		return common.ErrAccountDeleted
 	}
 	return nil
 }
 
-func (s *Storage) upsertSettings(ctx context.Context, tx pgx.Tx, settings *models.Settings) error {
+func (s *Storage) upsertSettings(ctx context.Context, tx pgx.Tx, settings *models.Settings, mask models.FieldMask) error {
 	if settings == nil {
 		return nil
 	}
-	query := `
-INSERT INTO settings (uuid, theme)
-VALUES ($1, $2)
-ON CONFLICT (uuid) DO UPDATE SET theme = excluded.theme
-`
-	res, err := tx.Exec(ctx, query, settings.UUID, settings.Theme)
+	columns := []column{
+		{name: "theme", path: "settings.theme", value: settings.Theme},
+		{name: "visibility", path: "settings.visibility", value: int8(settings.Visibility)},
+		{name: "hidden_until", path: "settings.hidden_until", value: settings.HiddenUntil},
+	}
+	query, args, ok := upsertQuery("settings", settings.UUID, columns, mask, false)
+	if !ok {
+		return nil
+	}
+	res, err := tx.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("err inserting settings for %s: %w", settings.UUID, err)
 	}
@@ -179,90 +344,148 @@ ON CONFLICT (uuid) DO UPDATE SET theme = excluded.theme
 	return nil
 }
 
-func (s *Storage) upsertPersonal(ctx context.Context, tx pgx.Tx, personal *models.Personal) error {
+func (s *Storage) upsertPersonal(ctx context.Context, tx pgx.Tx, personal *models.Personal, mask models.FieldMask) error {
 	if personal == nil {
 		return nil
 	}
-	query := `
-INSERT INTO personal (uuid, username, avatar_link, gender, age)
-VALUES ($1, $2, $3, $4, $5)
-ON CONFLICT (uuid) DO UPDATE SET username = excluded.username,
-								 avatar_link = excluded.avatar_link,
-								 gender = excluded.gender,
-								 age = excluded.age
-`
-	res, err := tx.Exec(ctx, query, personal.UUID, personal.Username, personal.AvatarLink, personal.Gender, personal.Age)
+	lifestyle := personal.Lifestyle
+	columns := []column{
+		{name: "username", path: "personal.username", value: personal.Username},
+		{name: "avatar_link", path: "personal.avatar_link", value: "", readOnly: true},
+		{name: "gender", path: "personal.gender", value: personal.Gender},
+		{name: "age", path: "personal.age", value: personal.Age},
+		{name: "smoking", path: "personal.lifestyle.smoking", value: lifestyle.Smoking},
+		{name: "pets", path: "personal.lifestyle.pets", value: lifestyle.Pets},
+		{name: "sleep_schedule", path: "personal.lifestyle.sleep_schedule", value: lifestyle.SleepSchedule},
+		{name: "cleanliness", path: "personal.lifestyle.cleanliness", value: lifestyle.Cleanliness},
+		{name: "guests", path: "personal.lifestyle.guests", value: lifestyle.Guests},
+	}
+	query, args, ok := upsertQuery("personal", personal.UUID, columns, mask, true)
+	if !ok {
+		return nil
+	}
+	res, err := tx.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("err inserting personal for %s: %w", personal.UUID, err)
 	}
 	if res.RowsAffected() == 0 {
 		return errors.New("err no rows affected while upserting personal")
 	}
-	return nil
+	// the avatar is the first photo of the gallery, which may be uploaded before the personal data is saved
+	return refreshAvatar(ctx, tx, personal.UUID)
 }
 
-func (s *Storage) upsertCriteria(ctx context.Context, tx pgx.Tx, criteria *models.SearchCriteria) error {
+func (s *Storage) upsertCriteria(ctx context.Context, tx pgx.Tx, criteria *models.SearchCriteria, mask models.FieldMask) error {
 	if criteria == nil {
 		return nil
 	}
-	query := `
-INSERT INTO search_criteria (uuid, price_from, price_to, gender, age_from, age_to)
-VALUES ($1, $2, $3, $4, $5, $6)
-ON CONFLICT (uuid) DO UPDATE SET price_from = excluded.price_from,
-								 price_to = excluded.price_to,
-								 gender = excluded.gender,
-								 age_from = excluded.age_from,
-								 age_to = excluded.age_to
-`
-	res, err := tx.Exec(ctx, query,
-		criteria.UUID,
-		criteria.PriceRange.From,
-		criteria.PriceRange.To,
-		criteria.Gender,
-		criteria.AgeRange.From,
-		criteria.AgeRange.To,
-	)
+	lifestyle := criteria.Lifestyle
+	lat, lon := pointColumns(criteria.Location)
+	columns := []column{
+		{name: "lat", path: "criteria.location.lat", value: lat},
+		{name: "lon", path: "criteria.location.lon", value: lon},
+		{name: "max_distance_km", path: "criteria.max_distance_km", value: criteria.MaxDistance},
+		{name: "price_from", path: "criteria.price_range.from", value: criteria.PriceRange.From},
+		{name: "price_to", path: "criteria.price_range.to", value: criteria.PriceRange.To},
+		{name: "gender", path: "criteria.gender", value: criteria.Gender},
+		{name: "age_from", path: "criteria.age_range.from", value: criteria.AgeRange.From},
+		{name: "age_to", path: "criteria.age_range.to", value: criteria.AgeRange.To},
+	}
+	preferences := []struct {
+		name       string
+		value      int8
+		importance models.Importance
+	}{
+		{"smoking", int8(lifestyle.Smoking.Value), lifestyle.Smoking.Importance},
+		{"pets", int8(lifestyle.Pets.Value), lifestyle.Pets.Importance},
+		{"sleep_schedule", int8(lifestyle.SleepSchedule.Value), lifestyle.SleepSchedule.Importance},
+		{"cleanliness", int8(lifestyle.Cleanliness.Value), lifestyle.Cleanliness.Importance},
+		{"guests", int8(lifestyle.Guests.Value), lifestyle.Guests.Importance},
+	}
+	for _, p := range preferences {
+		path := "criteria.lifestyle." + p.name
+		columns = append(columns,
+			column{name: p.name, path: path + ".value", value: p.value},
+			column{name: p.name + "_importance", path: path + ".importance", value: p.importance},
+		)
+	}
+	query, args, ok := upsertQuery("search_criteria", criteria.UUID, columns, mask, true)
+	if !ok {
+		// regions and stations reference the criteria row, so it has to exist even if only they are patched
+		query, args = `INSERT INTO search_criteria (uuid) VALUES ($1) ON CONFLICT (uuid) DO NOTHING`, []interface{}{criteria.UUID}
+	}
+	res, err := tx.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("err inserting criteria for %s: %w", criteria.UUID, err)
 	}
-	if res.RowsAffected() == 0 {
+	if ok && res.RowsAffected() == 0 {
 		return errors.New("err no rows affected while upserting criteria")
 	}
 	return nil
 }
 
-func (s *Storage) updateCriteriaRegions(ctx context.Context, tx pgx.Tx, uuid string, regions []int64) error {
-	query := `
-DELETE FROM uuid_regions
+// updateCriteriaIDs replaces the IDs of uuid stored in column of table, like the regions in uuid_regions.
+func (s *Storage) updateCriteriaIDs(ctx context.Context, tx pgx.Tx, table, column, uuid string, ids []int64) error {
+	query := fmt.Sprintf(`
+DELETE FROM %s
 WHERE uuid = $1
-`
-	_, err := tx.Exec(ctx, query, uuid)
+RETURNING %s
+`, table, column)
+	rows, err := tx.Query(ctx, query, uuid)
 	if err != nil {
-		return fmt.Errorf("err deleting old regions for %s: %w", uuid, err)
+		return fmt.Errorf("err deleting old %s for %s: %w", table, uuid, err)
 	}
-	rows := make([][]interface{}, 0, len(regions))
-	for _, region := range regions {
-		rows = append(rows, []interface{}{uuid, region})
+	var old []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("err scanning old %s for %s: %w", table, uuid, err)
+		}
+		old = append(old, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("err deleting old %s for %s: %w", table, uuid, err)
+	}
+	if !sameIDs(old, ids) {
+		if _, err = tx.Exec(ctx, `UPDATE search_criteria SET updated = now() WHERE uuid = $1`, uuid); err != nil {
+			return fmt.Errorf("err touching criteria of %s: %w", uuid, err)
+		}
+	}
+	newRows := make([][]interface{}, 0, len(ids))
+	for _, id := range ids {
+		newRows = append(newRows, []interface{}{uuid, id})
 	}
 	_, err = tx.CopyFrom(
-		ctx, pgx.Identifier{"uuid_regions"},
-		[]string{"uuid", "region_id"},
-		pgx.CopyFromRows(rows),
+		ctx, pgx.Identifier{table},
+		[]string{"uuid", column},
+		pgx.CopyFromRows(newRows),
 	)
 	if err != nil {
-		return fmt.Errorf("err inserting criteria regions: %w", err)
+		return fmt.Errorf("err inserting criteria %s: %w", table, err)
 	}
 	return nil
 }
 
 func (s *Storage) GetConfig(ctx context.Context, uuid string) (*models.Config, error) {
+	return s.loadConfig(ctx, s.db, uuid)
+}
+
+// querier is either the pool or a transaction.
+type querier interface {
+	pgxscan.Querier
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func (s *Storage) loadConfig(ctx context.Context, q querier, uuid string) (*models.Config, error) {
 	var cfg models.Config
 	cfg.UUID = uuid
-	if err := s.getConfig(ctx, uuid); err != nil {
+	if err := s.getConfig(ctx, q, uuid, &cfg); err != nil {
 		return nil, err
 	}
 	settings := models.Settings{}
-	err := s.getSettings(ctx, uuid, &settings)
+	err := s.getSettings(ctx, q, uuid, &settings)
 	switch {
 	case err == nil:
 		cfg.Settings = &settings
@@ -271,7 +494,7 @@ func (s *Storage) GetConfig(ctx context.Context, uuid string) (*models.Config, e
 		return nil, fmt.Errorf("err getting config for %s: %w", uuid, err)
 	}
 	personal := models.Personal{}
-	err = s.getPersonal(ctx, uuid, &personal)
+	err = s.getPersonal(ctx, q, uuid, &personal)
 	switch {
 	case err == nil:
 		cfg.Personal = &personal
@@ -280,7 +503,7 @@ func (s *Storage) GetConfig(ctx context.Context, uuid string) (*models.Config, e
 		return nil, fmt.Errorf("err getting config for %s: %w", uuid, err)
 	}
 	criteria := models.SearchCriteria{}
-	err = s.getSearchCriteria(ctx, uuid, &criteria)
+	err = s.getSearchCriteria(ctx, q, uuid, &criteria)
 	switch {
 	case err == nil:
 		cfg.Criteria = &criteria
@@ -291,10 +514,11 @@ func (s *Storage) GetConfig(ctx context.Context, uuid string) (*models.Config, e
 	return &cfg, nil
 }
 
-func (s *Storage) getConfig(ctx context.Context, uuid string) error {
-	row := s.db.QueryRow(ctx, `SELECT uuid FROM config WHERE uuid = $1`, uuid)
-	scannedUUID := ""
-	err := row.Scan(&scannedUUID)
+func (s *Storage) getConfig(ctx context.Context, q querier, uuid string, cfg *models.Config) error {
+	row := q.QueryRow(ctx, `SELECT role, force_hidden FROM config WHERE uuid = $1 AND deleted IS NULL`, uuid)
+	var role int8
+	err := row.Scan(&role, &cfg.ForceHidden)
+	cfg.Role = models.RoleOf(models.Role(role))
 	switch {
 	case err == nil:
 	case errors.Is(err, pgx.ErrNoRows):
@@ -305,26 +529,54 @@ func (s *Storage) getConfig(ctx context.Context, uuid string) error {
 	return nil
 }
 
-func (s *Storage) getSettings(ctx context.Context, uuid string, settings *models.Settings) error {
-	return pgxscan.Get(ctx, s.db, settings, `SELECT uuid, theme FROM settings WHERE uuid = $1`, uuid)
+func (s *Storage) getSettings(ctx context.Context, q querier, uuid string, settings *models.Settings) error {
+	dbSettings := Settings{}
+	err := pgxscan.Get(ctx, q, &dbSettings,
+		`SELECT uuid, theme, visibility, hidden_until FROM settings WHERE uuid = $1`, uuid)
+	if err != nil {
+		return err
+	}
+	DBSettings2Model(&dbSettings, settings)
+	return nil
 }
 
-func (s *Storage) getPersonal(ctx context.Context, uuid string, personal *models.Personal) error {
-	return pgxscan.Get(ctx, s.db, personal,
-		`SELECT uuid, username, avatar_link, gender, age FROM personal WHERE uuid = $1`, uuid)
+func (s *Storage) getPersonal(ctx context.Context, q querier, uuid string, personal *models.Personal) error {
+	dbPersonal := Personal{}
+	err := pgxscan.Get(ctx, q, &dbPersonal, `
+SELECT uuid, username, avatar_link, gender, age, smoking, pets, sleep_schedule, cleanliness, guests
+FROM personal WHERE uuid = $1`, uuid)
+	if err != nil {
+		return err
+	}
+	DBPersonal2Model(&dbPersonal, personal)
+	return nil
 }
 
-func (s *Storage) getSearchCriteria(ctx context.Context, uuid string, criteria *models.SearchCriteria) error {
+func (s *Storage) getSearchCriteria(ctx context.Context, q querier, uuid string, criteria *models.SearchCriteria) error {
 	dbCriteria := SearchCriteria{}
-	err := pgxscan.Get(ctx, s.db, &dbCriteria,
+	err := pgxscan.Get(ctx, q, &dbCriteria,
 		`
 SELECT uuid,
        (select array (select distinct region_id from uuid_regions where uuid = $1)) as regions,
+       (select array (select station_id from uuid_stations where uuid = $1 order by station_id)) as stations,
+       lat,
+       lon,
+       max_distance_km,
        price_from,
        price_to,
        gender,
        age_from,
-       age_to
+       age_to,
+       smoking,
+       smoking_importance,
+       pets,
+       pets_importance,
+       sleep_schedule,
+       sleep_schedule_importance,
+       cleanliness,
+       cleanliness_importance,
+       guests,
+       guests_importance
 FROM search_criteria WHERE uuid = $2`, uuid, uuid)
 	if err != nil {
 		return err
@@ -333,87 +585,272 @@ FROM search_criteria WHERE uuid = $2`, uuid, uuid)
 	return nil
 }
 
-func (s *Storage) GetRegions(ctx context.Context) ([]*models.Region, error) {
-	var regions []*models.Region
-	err := pgxscan.Select(ctx, s.db, &regions, "SELECT id, name, description FROM regions")
+// UpsertRelation saves the relation, records it in the history and keeps mutual_matches in sync
+// within one transaction.
+// It reports whether the users like each other after the relation is saved.
+func (s *Storage) UpsertRelation(ctx context.Context, relation *models.Relation) (bool, error) {
+	if relation == nil {
+		return false, nil
+	}
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		return nil, fmt.Errorf("err getting regions: %w", err)
+		return false, fmt.Errorf("err upserting relation: %w", err)
 	}
-	return regions, nil
-}
-
-func (s *Storage) UpsertRelation(ctx context.Context, relation *models.Relation) error {
-	if relation == nil {
-		return nil
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			s.log.Warnf("err rolling back tx during upserting relation: %v", err)
+		}
+	}()
+	if err = lockPair(ctx, tx, relation.UUID, relation.Target); err != nil {
+		return false, fmt.Errorf("err upserting relation: %w", err)
+	}
+	if err = lockAccounts(ctx, tx, relation.UUID, relation.Target); err != nil {
+		return false, err
+	}
+	var (
+		previous        *int8
+		previousUpdated *time.Time
+	)
+	row := tx.QueryRow(ctx, `SELECT relation, updated FROM relations WHERE uuid = $1 AND target = $2 FOR UPDATE`,
+		relation.UUID, relation.Target)
+	if err = row.Scan(&previous, &previousUpdated); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("err selecting previous relation for %s and %s: %w", relation.UUID, relation.Target, err)
 	}
 	query := `
-INSERT INTO relations (uuid, target, relation)
-VALUES ($1, $2, $3)
-ON CONFLICT (uuid, target) DO UPDATE SET relation = excluded.relation
+INSERT INTO relations (uuid, target, relation, created, updated)
+VALUES ($1, $2, $3, now(), now())
+ON CONFLICT (uuid, target) DO UPDATE SET relation = excluded.relation, updated = excluded.updated
 `
-	res, err := s.db.Exec(ctx, query, relation.UUID, relation.Target, relation.Relation)
+	res, err := tx.Exec(ctx, query, relation.UUID, relation.Target, relation.Relation)
 	if err != nil {
-		return fmt.Errorf("err inserting relation for %s and %s: %w", relation.UUID, relation.Target, err)
+		return false, fmt.Errorf("err inserting relation for %s and %s: %w", relation.UUID, relation.Target, err)
 	}
 	if res.RowsAffected() == 0 {
-		return errors.New("err no rows affected while upserting relation")
+		return false, errors.New("err no rows affected while upserting relation")
+	}
+	if err = s.recordRelationEvent(ctx, tx, relation, previous, previousUpdated); err != nil {
+		return false, fmt.Errorf("err upserting relation: %w", err)
+	}
+	matched, err := s.syncMutualMatch(ctx, tx, relation)
+	if err != nil {
+		return false, fmt.Errorf("err upserting relation: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("err committing upsert relation transaction: %w", err)
+	}
+	return matched, nil
+}
+
+// lockPair serializes the transactions changing relations between the two users until tx ends, so two
+// reciprocal likes made at once see each other and the mutual match is recorded.
+func lockPair(ctx context.Context, tx pgx.Tx, uuid1, uuid2 string) error {
+	uuid1, uuid2 = orderedPair(uuid1, uuid2)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1 || ' ' || $2))`, uuid1, uuid2); err != nil {
+		return fmt.Errorf("err locking relations of %s and %s: %w", uuid1, uuid2, err)
 	}
 	return nil
 }
 
-func (s *Storage) ListRelated(ctx context.Context, uuid string, relation Relation, limit, offset int64) ([]*models.Profile, error) { //nolint:lll
+// lockAccounts keeps the configs of uuids from being deleted until tx ends, it returns ErrConfigNotFound
+// if any of them is missing or already deleted.
+func lockAccounts(ctx context.Context, tx pgx.Tx, uuids ...string) error {
+	distinct := make(map[string]struct{}, len(uuids))
+	for _, uuid := range uuids {
+		distinct[uuid] = struct{}{}
+	}
+	var live int
+	err := tx.QueryRow(ctx, `
+SELECT count(*)
+FROM (SELECT uuid FROM config WHERE uuid = ANY ($1::text[]) AND deleted IS NULL FOR SHARE) AS live`, uuids).Scan(&live)
+	if err != nil {
+		return fmt.Errorf("err locking configs of %v: %w", uuids, err)
+	}
+	if live != len(distinct) {
+		return common.ErrConfigNotFound
+	}
+	return nil
+}
+
+// syncMutualMatch records or removes the mutual match of the pair after relation changed,
+// it reports whether a new match was recorded. The pair must be locked by lockPair.
+func (s *Storage) syncMutualMatch(ctx context.Context, tx pgx.Tx, relation *models.Relation) (bool, error) {
+	uuid1, uuid2 := orderedPair(relation.UUID, relation.Target)
+	liked := Relation(relation.Relation) == Liked || Relation(relation.Relation) == SuperLiked
+	if !liked {
+		_, err := tx.Exec(ctx, `DELETE FROM mutual_matches WHERE uuid1 = $1 AND uuid2 = $2`, uuid1, uuid2)
+		if err != nil {
+			return false, fmt.Errorf("err deleting mutual match for %s and %s: %w", uuid1, uuid2, err)
+		}
+		return false, nil
+	}
+	var reciprocal bool
+	row := tx.QueryRow(ctx, `
+SELECT EXISTS(SELECT 1 FROM relations WHERE uuid = $1 AND target = $2 AND relation IN ($3, $4))`,
+		relation.Target, relation.UUID, Liked, SuperLiked)
+	if err := row.Scan(&reciprocal); err != nil {
+		return false, fmt.Errorf("err checking reciprocal relation for %s: %w", relation.UUID, err)
+	}
+	if !reciprocal {
+		return false, nil
+	}
+	res, err := tx.Exec(ctx, `
+INSERT INTO mutual_matches (uuid1, uuid2, created)
+VALUES ($1, $2, $3)
+ON CONFLICT (uuid1, uuid2) DO NOTHING
+`, uuid1, uuid2, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("err inserting mutual match for %s and %s: %w", uuid1, uuid2, err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// ListMutualMatches returns uuids of users who liked each other with the given one, the latest matches first.
+// Blocked users are skipped.
+func (s *Storage) ListMutualMatches(ctx context.Context, uuid string, limit, offset int64) ([]string, error) {
 	var uuids []string
-	query := `SELECT target FROM relations WHERE uuid = $1 AND relation = $2`
-	if limit != 0 {
-		query += fmt.Sprintf("\nLIMIT %d OFFSET %d", limit, offset)
+	err := pgxscan.Select(ctx, s.db, &uuids, `
+SELECT CASE WHEN uuid1 = $1 THEN uuid2 ELSE uuid1 END AS uuid
+FROM mutual_matches
+WHERE (uuid1 = $1 OR uuid2 = $1)
+  AND NOT blocked(uuid1, uuid2)
+ORDER BY created DESC
+LIMIT $2 OFFSET $3`, uuid, limit, offset)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("err selecting mutual matches for %s: %w", uuid, err)
 	}
-	err := pgxscan.Select(ctx, s.db, &uuids, query, uuid, relation)
+	return uuids, nil
+}
+
+// ListRelated returns profiles the given user has the relation with, the latest first.
+// Users who aren't visible and blocked users are skipped.
+func (s *Storage) ListRelated(ctx context.Context, uuid string, relation Relation, limit int64, cursor string) ([]*models.Profile, *models.Page, error) { //nolint:lll
+	after, err := models.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []keysetRow
+	err = pgxscan.Select(ctx, s.db, &rows, `
+SELECT r.target AS uuid, r.updated AS ts
+FROM relations r
+         JOIN search_criteria c ON c.uuid = r.target
+         JOIN personal p ON p.uuid = r.target
+WHERE r.uuid = $1
+  AND r.relation = $2
+  AND shown(r.target)
+  AND NOT blocked(r.uuid, r.target)
+  AND ($3::timestamp IS NULL OR (r.updated, r.target) < ($3, $4))
+ORDER BY r.updated DESC, r.target DESC
+LIMIT $5`, uuid, relation, afterTimestamp(after), afterUUID(after), fetchLimit(limit))
+	if err != nil {
+		return nil, nil, fmt.Errorf("err selecting relations: %w", err)
+	}
+	page := models.Page{}
+	row := s.db.QueryRow(ctx, `
+SELECT count(*)
+FROM relations r
+         JOIN search_criteria c ON c.uuid = r.target
+         JOIN personal p ON p.uuid = r.target
+WHERE r.uuid = $1
+  AND r.relation = $2
+  AND shown(r.target)
+  AND NOT blocked(r.uuid, r.target)`,
+		uuid, relation)
+	if err = row.Scan(&page.Count); err != nil {
+		return nil, nil, fmt.Errorf("err counting relations: %w", err)
+	}
+	uuids := nextPage(rows, limit, &page)
+	var result []*models.Profile
+	err = s.getProfiles(ctx, &result, uuids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("err selecting related profiles: %w", err)
+	}
+	hideLocations(result)
+	return result, &page, nil
+}
+
+// ListAdmirers returns profiles of users who liked the given one, super likes first.
+// Users the given one has already disliked, users who aren't visible and blocked users are skipped.
+func (s *Storage) ListAdmirers(ctx context.Context, uuid string, limit, offset int64) ([]*models.Profile, error) {
+	var uuids []string
+	err := pgxscan.Select(ctx, s.db, &uuids, `
+SELECT r.uuid
+FROM relations r
+WHERE r.target = $1
+  AND r.relation IN ($2, $3)
+  AND NOT EXISTS(SELECT 1 FROM relations d WHERE d.uuid = $1 AND d.target = r.uuid AND d.relation = $4)
+  AND shown(r.uuid)
+  AND NOT blocked(r.uuid, r.target)
+ORDER BY r.relation = $3 DESC, r.uuid
+LIMIT $5 OFFSET $6`, uuid, Liked, SuperLiked, Disliked, limit, offset)
 	switch {
 	case err == nil:
 	case errors.Is(err, pgx.ErrNoRows):
 		return nil, nil
 	default:
-		return nil, fmt.Errorf("err selecting relations: %w", err)
+		return nil, fmt.Errorf("err selecting admirers: %w", err)
 	}
 	var result []*models.Profile
 	err = s.getProfiles(ctx, &result, uuids)
 	if err != nil {
-		return nil, fmt.Errorf("err selecting related profiles: %w", err)
+		return nil, fmt.Errorf("err selecting admirer profiles: %w", err)
 	}
+	hideLocations(result)
 	return result, nil
 }
 
+// getProfiles loads profiles of the given users in the order of uuids, users without personal data
+// or search criteria are skipped. The profiles carry the locations of the users, which must be hidden
+// before they are shown to others.
 func (s *Storage) getProfiles(ctx context.Context, profiles *[]*models.Profile, uuids []string) error {
-	if uuids == nil {
+	if len(uuids) == 0 {
 		return nil
 	}
 	var dbProfiles []Profile
-	quotedUUIDs := strings.Join(wrapQuoted(uuids), ",")
-	query := fmt.Sprintf(`
-SELECT criteria.uuid   AS uuid,
-       regions,
-       price_from,
-       price_to,
-       criteria.gender AS criteria_gender,
-       age_from,
-       age_to,
-       username,
-       avatar_link,
-       personal.gender AS personal_gender,
-       age
-FROM (SELECT search_criteria.uuid,
-       (select array (select distinct region_id from uuid_regions where uuid IN (%[1]s))) as regions,
-       price_from,
-       price_to,
-       gender,
-       age_from,
-       age_to
-FROM search_criteria
-WHERE search_criteria.uuid IN (%[1]s)) AS criteria
-JOIN (SELECT uuid, username, avatar_link, gender, age FROM personal WHERE uuid IN (%[1]s)
-) AS personal
-ON personal.uuid = criteria.uuid`, quotedUUIDs)
-	err := pgxscan.Select(ctx, s.db, &dbProfiles, query)
+	err := pgxscan.Select(ctx, s.db, &dbProfiles, `
+SELECT criteria.uuid                                            AS uuid,
+       (SELECT array(SELECT region_id
+                     FROM uuid_regions
+                     WHERE uuid_regions.uuid = criteria.uuid
+                     ORDER BY region_id))                       AS regions,
+       (SELECT array(SELECT station_id
+                     FROM uuid_stations
+                     WHERE uuid_stations.uuid = criteria.uuid
+                     ORDER BY station_id))                      AS stations,
+       criteria.lat,
+       criteria.lon,
+       criteria.max_distance_km,
+       criteria.price_from,
+       criteria.price_to,
+       criteria.gender                                          AS criteria_gender,
+       criteria.age_from,
+       criteria.age_to,
+       personal.username,
+       personal.avatar_link,
+       personal.gender                                          AS personal_gender,
+       personal.age,
+       personal.smoking,
+       personal.pets,
+       personal.sleep_schedule,
+       personal.cleanliness,
+       personal.guests,
+       criteria.smoking                                         AS "pref.smoking",
+       criteria.smoking_importance                              AS "pref.smoking_importance",
+       criteria.pets                                            AS "pref.pets",
+       criteria.pets_importance                                 AS "pref.pets_importance",
+       criteria.sleep_schedule                                  AS "pref.sleep_schedule",
+       criteria.sleep_schedule_importance                       AS "pref.sleep_schedule_importance",
+       criteria.cleanliness                                     AS "pref.cleanliness",
+       criteria.cleanliness_importance                          AS "pref.cleanliness_importance",
+       criteria.guests                                          AS "pref.guests",
+       criteria.guests_importance                               AS "pref.guests_importance"
+FROM search_criteria AS criteria
+         JOIN personal ON personal.uuid = criteria.uuid
+WHERE criteria.uuid = ANY ($1::text[])
+ORDER BY array_position($1::text[], criteria.uuid)`, uuids)
 	switch {
 	case err == nil:
 		for i := range dbProfiles {
@@ -431,64 +868,230 @@ func (s *Storage) GetProfiles(ctx context.Context, uuids []string) ([]*models.Pr
 	if err := s.getProfiles(ctx, &profiles, uuids); err != nil {
 		return nil, fmt.Errorf("err loading list of profiles: %w", err)
 	}
+	hideLocations(profiles)
 	return profiles, nil
 }
 
-func (s *Storage) ListMatches(ctx context.Context, uuid string, count int64) ([]*models.Profile, error) {
-	var uuids []string
-	err := pgxscan.Select(ctx, s.db, &uuids,
+// hideLocations removes the locations of users from their profiles: others can be matched by the distance,
+// but mustn't learn where the users are.
+func hideLocations(profiles []*models.Profile) {
+	for _, profile := range profiles {
+		if profile.Criteria != nil {
+			profile.Criteria.Location = nil
+		}
+	}
+}
+
+// ListMatches returns up to count best matches for the given user ranked by compatibility,
+// the score is computed by match_score.
+func (s *Storage) ListMatches(ctx context.Context, uuid string, count int64, cursor string) ([]*models.Profile, *models.Page, error) { //nolint:lll
+	after, err := models.DecodeCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	ttlRelations := make([]int16, 0, len(s.relationTTLs))
+	ttlSeconds := make([]float64, 0, len(s.relationTTLs))
+	for relation, ttl := range s.relationTTLs {
+		ttlRelations = append(ttlRelations, int16(relation))
+		ttlSeconds = append(ttlSeconds, ttl.Seconds())
+	}
+	var rows []scoredRow
+	err = pgxscan.Select(ctx, s.db, &rows,
 		`
-WITH uuids AS (SELECT DISTINCT uuid
-               FROM uuid_regions
-               WHERE region_id IN (SELECT region_id FROM uuid_regions WHERE uuid = $1)
-                 AND uuid NOT IN (SELECT DISTINCT target FROM relations WHERE uuid = $1)
-                 AND uuid != $1),
-     criteria AS (SELECT price_from, price_to, gender, age_from, age_to FROM search_criteria WHERE uuid = $1),
-     self AS (SELECT gender, age FROM personal WHERE uuid = $1)
-SELECT uuid
-FROM search_criteria
-WHERE 1 = 1
-  AND uuid IN (SELECT criteria.uuid as uuid
+WITH criteria AS (SELECT price_from,
+                         price_to,
+                         gender,
+                         age_from,
+                         age_to,
+                         lat,
+                         lon,
+                         max_distance_km,
+                         smoking,
+                         smoking_importance,
+                         pets,
+                         pets_importance,
+                         sleep_schedule,
+                         sleep_schedule_importance,
+                         cleanliness,
+                         cleanliness_importance,
+                         guests,
+                         guests_importance
+                  FROM search_criteria
+                  WHERE uuid = $1),
+     self AS (SELECT gender, age, smoking, pets, sleep_schedule, cleanliness, guests FROM personal WHERE uuid = $1),
+     owners AS (SELECT uuid FROM config WHERE role = $3),
+     -- tombstones of deleted accounts
+     deleted AS (SELECT uuid FROM config WHERE deleted IS NOT NULL),
+     -- relations of types $4 expire after $5 seconds if the target has changed since
+     related AS (SELECT r.target
+                 FROM relations r
+                          LEFT JOIN unnest($4::smallint[], $5::double precision[]) AS ttl(relation, seconds)
+                                    ON ttl.relation = r.relation
+                 WHERE r.uuid = $1
+                   AND (ttl.seconds IS NULL
+                     OR r.updated > now() - make_interval(secs => ttl.seconds)
+                     OR NOT EXISTS(SELECT 1 FROM personal p WHERE p.uuid = r.target AND p.updated > r.updated)
+                         AND NOT EXISTS(SELECT 1
+                                        FROM search_criteria c
+                                        WHERE c.uuid = r.target
+                                          AND c.updated > r.updated))),
+     -- regions overlapping the wanted ones: they, their districts and the okrugs and cities containing them
+     wanted AS (SELECT related_regions(array(SELECT region_id FROM uuid_regions WHERE uuid = $1)) AS region_id),
+     -- stations on the lines of the preferred ones and within $6 stops of them
+     near AS (SELECT nearby_stations(array(SELECT station_id FROM uuid_stations WHERE uuid = $1), $6) AS station_id),
+     -- the location and the max distance of the criteria, users and listings around it are found by
+     -- the bounding boxes first and then by the exact distance
+     area AS (SELECT lat, lon, max_distance_km AS km
+              FROM criteria
+              WHERE lat IS NOT NULL
+                AND max_distance_km IS NOT NULL),
+     around AS (SELECT c.uuid
+                FROM search_criteria c,
+                     area,
+                     bounding_boxes(area.lat, area.lon, area.km) AS bounds
+                WHERE point(c.lon, c.lat) <@ bounds
+                  AND distance_km(area.lat, area.lon, c.lat, c.lon) <= area.km),
+     placed AS (SELECT l.id
+                FROM listings l,
+                     area,
+                     bounding_boxes(area.lat, area.lon, area.km) AS bounds
+                WHERE point(l.lon, l.lat) <@ bounds
+                  AND distance_km(area.lat, area.lon, l.lat, l.lon) <= area.km),
+     -- listing owners are paired by their listings instead of their own regions and price range
+     listed AS (SELECT DISTINCT uuid
+                FROM listings
+                WHERE (region_id IN (SELECT region_id FROM wanted) OR id IN (SELECT id FROM placed))
+                  AND rent >= (SELECT COALESCE(price_from, 0) FROM criteria)
+                  AND rent <= (SELECT COALESCE(price_to, 999999999999) FROM criteria)),
+     uuids AS (SELECT DISTINCT uuid
                FROM (SELECT uuid
-                     FROM personal
-                     WHERE 1 = 1
-                       AND uuid IN (SELECT * FROM uuids)
-                       AND (gender = (SELECT gender FROM criteria) OR
-                            (SELECT gender FROM criteria) = 0) -- if 0 client doesn't care
-                       AND age >= (SELECT COALESCE(age_from, 0) FROM criteria)
-                       AND age <= (SELECT COALESCE(age_to, 999) FROM criteria)) AS personal
-                        JOIN (SELECT uuid
-                              FROM search_criteria
-                              WHERE 1 = 1
-                                AND uuid IN (SELECT * FROM uuids)
-                                AND COALESCE(price_from, 0) <= (SELECT COALESCE(price_to, 999999999999) FROM criteria)
-                                AND COALESCE(price_to, 999999999999) >=
-                                    (SELECT COALESCE(price_from, 0) FROM criteria)) AS criteria
-                             ON personal.uuid = criteria.uuid)
-  AND (gender = 0 OR gender = (SELECT gender FROM self))
-  AND COALESCE(age_from, 0) <= (SELECT age FROM self)
-  AND COALESCE(age_to, 999) >= (SELECT age FROM self)
-LIMIT $2
-`, uuid, count)
-	switch {
-	case err == nil:
-	case errors.Is(err, pgx.ErrNoRows):
-		return nil, nil
-	default:
-		return nil, fmt.Errorf("err selecting matches by region: %w", err)
+                     FROM uuid_regions
+                     WHERE region_id IN (SELECT region_id FROM wanted)
+                       AND uuid NOT IN (SELECT uuid FROM owners)
+                     UNION
+                     SELECT uuid
+                     FROM uuid_stations
+                     WHERE station_id IN (SELECT station_id FROM near)
+                       AND uuid NOT IN (SELECT uuid FROM owners)
+                     UNION
+                     SELECT uuid
+                     FROM around
+                     WHERE uuid NOT IN (SELECT uuid FROM owners)
+                     UNION
+                     SELECT uuid
+                     FROM listed) AS candidates
+               WHERE uuid NOT IN (SELECT target FROM related)
+                 AND uuid NOT IN (SELECT uuid FROM deleted)
+                 AND shown(uuid)
+                 AND NOT blocked(uuid, $1)
+                 AND uuid != $1)
+     matches AS (SELECT uuid
+                 FROM search_criteria
+                 WHERE 1 = 1
+                   AND uuid IN (SELECT criteria.uuid as uuid
+                                FROM (SELECT uuid
+                                      FROM personal
+                                      WHERE 1 = 1
+                                        AND uuid IN (SELECT uuid FROM uuids)
+                                        AND (gender = (SELECT gender FROM criteria) OR
+                                             (SELECT gender FROM criteria) = 0) -- if 0 client doesn't care
+                                        AND age >= (SELECT COALESCE(age_from, 0) FROM criteria)
+                                        AND age <= (SELECT COALESCE(age_to, 999) FROM criteria)
+                                        -- lifestyle preferences with importance $2 (must) are hard constraints
+                                        AND ((SELECT smoking_importance FROM criteria) != $2 OR
+                                             smoking = (SELECT smoking FROM criteria))
+                                        AND ((SELECT pets_importance FROM criteria) != $2 OR
+                                             pets = (SELECT pets FROM criteria))
+                                        AND ((SELECT sleep_schedule_importance FROM criteria) != $2 OR
+                                             sleep_schedule = (SELECT sleep_schedule FROM criteria))
+                                        AND ((SELECT cleanliness_importance FROM criteria) != $2 OR
+                                             cleanliness = (SELECT cleanliness FROM criteria))
+                                        AND ((SELECT guests_importance FROM criteria) != $2 OR
+                                             guests = (SELECT guests FROM criteria))) AS personal
+                                         JOIN (SELECT uuid
+                                               FROM search_criteria
+                                               WHERE 1 = 1
+                                                 AND uuid IN (SELECT uuid FROM uuids)
+                                                 AND (uuid IN (SELECT uuid FROM owners) OR
+                                                      COALESCE(price_from, 0) <=
+                                                      (SELECT COALESCE(price_to, 999999999999) FROM criteria)
+                                                          AND COALESCE(price_to, 999999999999) >=
+                                                              (SELECT COALESCE(price_from, 0) FROM criteria))) AS criteria
+                                              ON personal.uuid = criteria.uuid)
+                   AND (gender = 0 OR gender = (SELECT gender FROM self))
+                   AND COALESCE(age_from, 0) <= (SELECT age FROM self)
+                   AND COALESCE(age_to, 999) >= (SELECT age FROM self)
+                   AND (smoking_importance != $2 OR smoking = (SELECT smoking FROM self))
+                   AND (pets_importance != $2 OR pets = (SELECT pets FROM self))
+                   AND (sleep_schedule_importance != $2 OR sleep_schedule = (SELECT sleep_schedule FROM self))
+                   AND (cleanliness_importance != $2 OR cleanliness = (SELECT cleanliness FROM self))
+                   AND (guests_importance != $2 OR guests = (SELECT guests FROM self))),
+     scored AS (SELECT m.uuid, s.score, s.matched_criteria
+                FROM matches m
+                         CROSS JOIN LATERAL match_score($1, m.uuid, array(SELECT region_id FROM wanted),
+                                                        array(SELECT station_id FROM near)) AS s)
+-- the total is returned even if the page is empty
+SELECT total.count, page.uuid, page.score, page.matched_criteria
+FROM (SELECT count(*) FROM scored) AS total
+         LEFT JOIN LATERAL (SELECT uuid, score, matched_criteria
+                            FROM scored
+                            WHERE $7::double precision IS NULL
+                               OR score < $7
+                               OR score = $7 AND uuid > $8
+                            ORDER BY score DESC, uuid
+                            LIMIT $9) AS page ON true
+`, uuid, models.Must, models.ListingOwner, ttlRelations, ttlSeconds, s.stationStops,
+		afterScore(after), afterUUID(after), fetchLimit(count))
+	if err != nil {
+		return nil, nil, fmt.Errorf("err selecting matches by region, stations and distance: %w", err)
 	}
-	var result []*models.Profile
-	err = s.getProfiles(ctx, &result, uuids)
+	page := models.Page{}
+	uuids, scores := nextScoredPage(rows, count, &page)
+	var matches []*models.Profile
+	if err = s.getProfiles(ctx, &matches, uuids); err != nil {
+		return nil, nil, fmt.Errorf("err getting matches for %s: %w", uuid, err)
+	}
+	hideLocations(matches)
+	for _, match := range matches {
+		match.Score = *scores[match.UUID].Score
+		match.MatchedCriteria = scores[match.UUID].MatchedCriteria
+	}
+	if err = s.attachListings(ctx, uuid, matches); err != nil {
+		return nil, nil, fmt.Errorf("err getting matches for %s: %w", uuid, err)
+	}
+	return matches, &page, nil
+}
+
+// attachListings adds listings fitting the criteria of uuid to the matched profiles of their owners.
+func (s *Storage) attachListings(ctx context.Context, uuid string, matches []*models.Profile) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	owners := make([]string, 0, len(matches))
+	for _, match := range matches {
+		owners = append(owners, match.UUID)
+	}
+	listings, err := s.listingsFor(ctx, uuid, owners)
 	if err != nil {
-		return nil, fmt.Errorf("err getting matches for %s: %w", uuid, err)
+		return err
 	}
-	return result, nil
+	for _, match := range matches {
+		match.Listings = listings[match.UUID]
+	}
+	return nil
 }
 
-func wrapQuoted(elems []string) []string {
-	result := make([]string, 0, len(elems))
-	for _, elem := range elems {
-		result = append(result, "'"+elem+"'")
+func sameIDs(a, b []int64) bool {
+	set := make(map[int64]struct{}, len(a))
+	for _, id := range a {
+		set[id] = struct{}{}
+	}
+	other := make(map[int64]struct{}, len(b))
+	for _, id := range b {
+		if _, ok := set[id]; !ok {
+			return false
+		}
+		other[id] = struct{}{}
 	}
-	return result
+	return len(set) == len(other)
 }
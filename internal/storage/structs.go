@@ -1,15 +1,102 @@
 package storage
 
-import "github.com/gerladeno/homie-core/internal/models"
+import (
+	"time"
+
+	"github.com/gerladeno/homie-core/internal/models"
+	"github.com/gerladeno/homie-core/pkg/chat"
+)
+
+type Lifestyle struct {
+	Smoking       int8 `db:"smoking"`
+	Pets          int8 `db:"pets"`
+	SleepSchedule int8 `db:"sleep_schedule"`
+	Cleanliness   int8 `db:"cleanliness"`
+	Guests        int8 `db:"guests"`
+}
+
+func (l *Lifestyle) model() models.Lifestyle {
+	return models.Lifestyle{
+		Smoking:       models.Smoking(l.Smoking),
+		Pets:          models.Pets(l.Pets),
+		SleepSchedule: models.SleepSchedule(l.SleepSchedule),
+		Cleanliness:   models.Cleanliness(l.Cleanliness),
+		Guests:        models.Guests(l.Guests),
+	}
+}
+
+type LifestylePreferences struct {
+	Smoking                 int8 `db:"smoking"`
+	SmokingImportance       int8 `db:"smoking_importance"`
+	Pets                    int8 `db:"pets"`
+	PetsImportance          int8 `db:"pets_importance"`
+	SleepSchedule           int8 `db:"sleep_schedule"`
+	SleepScheduleImportance int8 `db:"sleep_schedule_importance"`
+	Cleanliness             int8 `db:"cleanliness"`
+	CleanlinessImportance   int8 `db:"cleanliness_importance"`
+	Guests                  int8 `db:"guests"`
+	GuestsImportance        int8 `db:"guests_importance"`
+}
+
+func (l *LifestylePreferences) model() models.LifestylePreferences {
+	return models.LifestylePreferences{
+		Smoking:       preference[models.Smoking](l.Smoking, l.SmokingImportance),
+		Pets:          preference[models.Pets](l.Pets, l.PetsImportance),
+		SleepSchedule: preference[models.SleepSchedule](l.SleepSchedule, l.SleepScheduleImportance),
+		Cleanliness:   preference[models.Cleanliness](l.Cleanliness, l.CleanlinessImportance),
+		Guests:        preference[models.Guests](l.Guests, l.GuestsImportance),
+	}
+}
+
+func preference[T models.Trait](value, importance int8) models.Preference[T] {
+	return models.Preference[T]{Value: T(value), Importance: models.Importance(importance)}
+}
+
+type Settings struct {
+	UUID        string     `db:"uuid"`
+	Theme       int64      `db:"theme"`
+	Visibility  int8       `db:"visibility"`
+	HiddenUntil *time.Time `db:"hidden_until"`
+}
+
+func DBSettings2Model(dbSettings *Settings, settings *models.Settings) {
+	settings.UUID = dbSettings.UUID
+	settings.Theme = dbSettings.Theme
+	settings.Visibility = models.Visibility(dbSettings.Visibility)
+	settings.HiddenUntil = dbSettings.HiddenUntil
+}
+
+type Personal struct {
+	UUID       string `db:"uuid"`
+	Username   string `db:"username"`
+	AvatarLink string `db:"avatar_link"`
+	Gender     int8   `db:"gender"`
+	Age        int8   `db:"age"`
+	Lifestyle
+}
+
+func DBPersonal2Model(dbPersonal *Personal, personal *models.Personal) {
+	personal.UUID = dbPersonal.UUID
+	personal.Username = dbPersonal.Username
+	personal.AvatarLink = dbPersonal.AvatarLink
+	personal.Gender = models.Gender(dbPersonal.Gender)
+	personal.Age = dbPersonal.Age
+	personal.Lifestyle = dbPersonal.Lifestyle.model()
+}
 
 type SearchCriteria struct {
-	UUID      string   `db:"uuid"`
-	Regions   []int64  `db:"regions"`
-	PriceFrom *float64 `db:"price_from"`
-	PriceTo   *float64 `db:"price_to"`
-	Gender    int8     `db:"gender"`
-	AgeFrom   *float64 `db:"age_from"`
-	AgeTo     *float64 `db:"age_to"`
+	UUID        string   `db:"uuid"`
+	Regions     []int64  `db:"regions"`
+	Stations    []int64  `db:"stations"`
+	Lat         *float64 `db:"lat"`
+	Lon         *float64 `db:"lon"`
+	MaxDistance *float64 `db:"max_distance_km"`
+	PriceFrom   *float64 `db:"price_from"`
+	PriceTo     *float64 `db:"price_to"`
+	Gender      int8     `db:"gender"`
+	AgeFrom     *float64 `db:"age_from"`
+	AgeTo       *float64 `db:"age_to"`
+	LifestylePreferences
 }
 
 func DBCriteria2Model(dbCriteria *SearchCriteria, criteria *models.SearchCriteria) {
@@ -19,15 +106,23 @@ func DBCriteria2Model(dbCriteria *SearchCriteria, criteria *models.SearchCriteri
 	}
 	criteria.UUID = dbCriteria.UUID
 	criteria.Regions = dbCriteria.Regions
+	criteria.Stations = dbCriteria.Stations
+	criteria.Location = dbPoint(dbCriteria.Lat, dbCriteria.Lon)
+	criteria.MaxDistance = dbCriteria.MaxDistance
 
 	criteria.PriceRange = models.Range{From: dbCriteria.PriceFrom, To: dbCriteria.PriceTo}
 	criteria.Gender = models.Gender(dbCriteria.Gender)
 	criteria.AgeRange = models.Range{From: dbCriteria.AgeFrom, To: dbCriteria.AgeTo}
+	criteria.Lifestyle = dbCriteria.LifestylePreferences.model()
 }
 
 type Profile struct {
 	UUID           string   `db:"uuid"`
 	Regions        []int64  `db:"regions"`
+	Stations       []int64  `db:"stations"`
+	Lat            *float64 `db:"lat"`
+	Lon            *float64 `db:"lon"`
+	MaxDistance    *float64 `db:"max_distance_km"`
 	PriceFrom      *float64 `db:"price_from"`
 	PriceTo        *float64 `db:"price_to"`
 	CriteriaGender int8     `db:"criteria_gender"`
@@ -37,6 +132,8 @@ type Profile struct {
 	AvatarLink     string   `db:"avatar_link"`
 	PersonalGender int8     `db:"personal_gender"`
 	Age            int8     `db:"age"`
+	Lifestyle
+	Preferences LifestylePreferences `db:"pref"`
 }
 
 func DBProfile2Profile(profile *Profile) *models.Profile {
@@ -51,14 +148,56 @@ func DBProfile2Profile(profile *Profile) *models.Profile {
 			AvatarLink: profile.AvatarLink,
 			Gender:     models.Gender(profile.PersonalGender),
 			Age:        profile.Age,
+			Lifestyle:  profile.Lifestyle.model(),
 		},
 		Criteria: &models.SearchCriteria{
-			UUID:       profile.UUID,
-			Regions:    profile.Regions,
-			PriceRange: models.Range{From: profile.PriceFrom, To: profile.PriceTo},
-			Gender:     models.Gender(profile.CriteriaGender),
-			AgeRange:   models.Range{From: profile.AgeFrom, To: profile.AgeTo},
+			UUID:        profile.UUID,
+			Regions:     profile.Regions,
+			Stations:    profile.Stations,
+			Location:    dbPoint(profile.Lat, profile.Lon),
+			MaxDistance: profile.MaxDistance,
+			PriceRange:  models.Range{From: profile.PriceFrom, To: profile.PriceTo},
+			Gender:      models.Gender(profile.CriteriaGender),
+			AgeRange:    models.Range{From: profile.AgeFrom, To: profile.AgeTo},
+			Lifestyle:   profile.Preferences.model(),
 		},
 	}
 	return &p
 }
+
+// dbPoint makes a point of nullable lat and lon columns.
+func dbPoint(lat, lon *float64) *models.Point {
+	if lat == nil || lon == nil {
+		return nil
+	}
+	return &models.Point{Lat: *lat, Lon: *lon}
+}
+
+// pointColumns splits a nullable point into lat and lon columns.
+func pointColumns(point *models.Point) (*float64, *float64) {
+	if point == nil {
+		return nil, nil
+	}
+	return &point.Lat, &point.Lon
+}
+
+type Message struct {
+	ID        int64     `db:"id"`
+	Sender    string    `db:"sender"`
+	Receiver  string    `db:"receiver"`
+	Timestamp time.Time `db:"timestamp"`
+	Body      string    `db:"body"`
+}
+
+func DBMessage2Message(message *Message) *chat.Message {
+	if message == nil {
+		return nil
+	}
+	return &chat.Message{
+		ID:        message.ID,
+		Sender:    message.Sender,
+		Receiver:  message.Receiver,
+		Timestamp: message.Timestamp.UTC().Format(time.RFC3339Nano),
+		Body:      message.Body,
+	}
+}
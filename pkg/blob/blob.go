@@ -0,0 +1,8 @@
+package blob
+
+import "errors"
+
+var (
+	ErrNotFound   = errors.New("err blob not found")
+	ErrInvalidKey = errors.New("err invalid blob key")
+)
@@ -0,0 +1,96 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps blobs as files under a root directory. Keys are slash separated relative paths.
+type LocalStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalStore creates the root directory if needed, baseURL is the address the blobs are served at.
+func NewLocalStore(root, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("err creating blob directory %s: %w", root, err)
+	}
+	return &LocalStore{root: root, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (s *LocalStore) Put(_ context.Context, key string, r io.Reader) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("err creating directory for %s: %w", key, err)
+	}
+	// write to a temporary file first, so readers never see a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("err creating file for %s: %w", key, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("err writing %s: %w", key, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("err writing %s: %w", key, err)
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("err saving %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist):
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("err opening %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("err deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// path maps key to a file name, rejecting keys that point outside of the root.
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "..") ||
+		strings.Contains(key, "\\") || strings.HasPrefix(path.Base(key), ".") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,38 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir(), "https://example.com/static/photos/")
+	require.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "first/photo.jpg", strings.NewReader("data")))
+		r, err := store.Get(ctx, "first/photo.jpg")
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, "data", string(data))
+		require.Equal(t, "https://example.com/static/photos/first/photo.jpg", store.URL("first/photo.jpg"))
+
+		require.NoError(t, store.Delete(ctx, "first/photo.jpg"))
+		_, err = store.Get(ctx, "first/photo.jpg")
+		require.ErrorIs(t, err, ErrNotFound)
+		require.NoError(t, store.Delete(ctx, "first/photo.jpg"))
+	})
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "../photo.jpg", "/etc/passwd", "first/../../photo.jpg", "first/.tmp-1"} {
+			_, err := store.Get(ctx, key)
+			require.ErrorIs(t, err, ErrInvalidKey, key)
+		}
+	})
+}
@@ -2,6 +2,9 @@ package chat
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -21,6 +24,9 @@ const (
 
 	// Maximum message size allowed from peer.
 	maxMessageSize = 512
+
+	// Number of the latest messages sent to a client right after it connects.
+	replaySize = 50
 )
 
 var (
@@ -34,13 +40,15 @@ var upgrader = websocket.Upgrader{
 }
 
 type Client struct {
+	uuid string
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn, send chan []byte) *Client {
+func NewClient(uuid string, hub *Hub, conn *websocket.Conn, send chan []byte) *Client {
 	return &Client{
+		uuid: uuid,
 		hub:  hub,
 		conn: conn,
 		send: send,
@@ -49,7 +57,10 @@ func NewClient(hub *Hub, conn *websocket.Conn, send chan []byte) *Client {
 
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
@@ -64,8 +75,41 @@ func (c *Client) readPump() {
 			break
 		}
 		message = bytes.TrimSpace(bytes.ReplaceAll(message, newline, space))
-		c.hub.broadcast <- message
+		if len(message) == 0 {
+			continue
+		}
+		data, err := c.saveMessage(string(message))
+		if err != nil {
+			log.Printf("error: %v", err)
+			continue
+		}
+		select {
+		case c.hub.broadcast <- data:
+		case <-c.hub.done:
+			return
+		}
+	}
+}
+
+// saveMessage stamps the body with the authenticated sender and the server time,
+// persists it and returns the JSON frame to be sent to the dialog.
+func (c *Client) saveMessage(body string) ([]byte, error) {
+	m := Message{
+		Sender:    c.uuid,
+		Receiver:  c.hub.peer(c.uuid),
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Body:      body,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	if err := c.hub.store.SaveMessage(ctx, &m); err != nil {
+		return nil, fmt.Errorf("err saving message from %s: %w", c.uuid, err)
+	}
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return nil, fmt.Errorf("err marshaling message from %s: %w", c.uuid, err)
 	}
+	return data, nil
 }
 
 func (c *Client) writePump() {
@@ -74,6 +118,10 @@ func (c *Client) writePump() {
 		ticker.Stop()
 		c.conn.Close()
 	}()
+	if err := c.replay(); err != nil {
+		log.Printf("error: %v", err)
+		return
+	}
 	for {
 		select {
 		case message, ok := <-c.send:
@@ -109,14 +157,42 @@ func (c *Client) writePump() {
 	}
 }
 
-func WebsocketChatHandler(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// replay writes the tail of the dialog history so the client sees what was said before it connected.
+// The client is registered first, so the messages sent meanwhile wait in send, one of them may also
+// be replayed, clients dedupe messages by id.
+func (c *Client) replay() error {
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	messages, err := c.hub.store.LoadMessages(ctx, c.hub.uuid1, c.hub.uuid2, time.Now(), 0, replaySize)
+	if err != nil {
+		return fmt.Errorf("err loading history for %s: %w", c.uuid, err)
+	}
+	for _, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("err marshaling history for %s: %w", c.uuid, err)
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err = c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return fmt.Errorf("err writing history for %s: %w", c.uuid, err)
+		}
+	}
+	return nil
+}
+
+func WebsocketChatHandler(hub *Hub, uuid string, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	client := NewClient(hub, conn, make(chan []byte, 256))
-	client.hub.register <- client
+	client := NewClient(uuid, hub, conn, make(chan []byte, 256))
+	select {
+	case client.hub.register <- client:
+	case <-client.hub.done:
+		conn.Close()
+		return
+	}
 
 	go client.writePump()
 	go client.readPump()
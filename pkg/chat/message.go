@@ -1,6 +1,7 @@
 package chat
 
 type Message struct {
+	ID        int64  `json:"id"`
 	Sender    string `json:"sender"`
 	Receiver  string `json:"receiver"`
 	Timestamp string `json:"timestamp"`
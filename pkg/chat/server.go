@@ -2,7 +2,11 @@ package chat
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
+
+	"github.com/gerladeno/homie-core/pkg/common"
 )
 
 type Store interface {
@@ -11,68 +15,170 @@ type Store interface {
 	GetAllChats(ctx context.Context, uuid string) ([]string, error)
 	SaveMessage(ctx context.Context, m *Message) error
 	LoadAllMessages(ctx context.Context, uuid1, uuid2 string) ([]*Message, error)
+	LoadMessages(ctx context.Context, uuid1, uuid2 string, before time.Time, beforeID, limit int64) ([]*Message, error)
+	EachMessage(ctx context.Context, uuid1, uuid2 string, fn func(m *Message) error) error
 }
 
+// MaxHistoryLimit caps how many messages of the history are loaded at once.
+const MaxHistoryLimit = 100
+
+// Authorizer reports whether client may chat with target.
+type Authorizer func(ctx context.Context, client, target string) (bool, error)
+
 type Server struct {
 	store Store
+	allow Authorizer
 	hubs  map[string]map[string]*Hub
 	mx    sync.Mutex
 }
 
-func NewServer() *Server {
+func NewServer(store Store, allow Authorizer) *Server {
 	s := Server{
 		hubs:  make(map[string]map[string]*Hub),
-		store: fakeStore{},
+		store: store,
+		allow: allow,
 	}
 	return &s
 }
 
-func (s *Server) GetDialog(_ context.Context, client, target string) *Hub {
+// GetDialog returns the hub of the dialog between client and target. A dialog can only be opened
+// by users the authorizer allows to chat, the chat is recorded the first time it is opened.
+func (s *Server) GetDialog(ctx context.Context, client, target string) (*Hub, error) {
+	allowed, err := s.allow(ctx, client, target)
+	if err != nil {
+		return nil, fmt.Errorf("err checking relations between %s and %s: %w", client, target, err)
+	}
+	if !allowed {
+		return nil, common.ErrChatForbidden
+	}
+	if h := s.lookup(client, target); h != nil {
+		return h, nil
+	}
+	// the chat is saved outside of the lock so that a slow insert doesn't stall other dialogs
+	if err = s.store.SaveChat(ctx, client, target); err != nil {
+		return nil, fmt.Errorf("err saving chat between %s and %s: %w", client, target, err)
+	}
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	m, ok := s.hubs[client]
+	h, ok := s.hubs[client][target]
 	if !ok {
-		m = make(map[string]*Hub)
-		s.hubs[client] = m
-	}
-	h, ok := m[target]
-	if !ok {
-		h = newHub()
+		// nobody opened the dialog meanwhile
+		h = newHub(s.store, client, target)
 		go h.run()
-		m[target] = h
 	}
-	m, ok = s.hubs[target]
-	if !ok {
-		m = make(map[string]*Hub)
-		s.hubs[target] = m
+	for _, pair := range [][2]string{{client, target}, {target, client}} {
+		m, ok := s.hubs[pair[0]]
+		if !ok {
+			m = make(map[string]*Hub)
+			s.hubs[pair[0]] = m
+		}
+		m[pair[1]] = h
+	}
+	return h, nil
+}
+
+// lookup returns the hub of an already opened dialog between client and target or nil.
+func (s *Server) lookup(client, target string) *Hub {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.hubs[client][target]
+}
+
+// CloseDialogs disconnects everyone from the dialogs of uuid and forgets them.
+func (s *Server) CloseDialogs(uuid string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for target, h := range s.hubs[uuid] {
+		h.stop()
+		delete(s.hubs[target], uuid)
 	}
-	m[client] = h
-	return h
+	delete(s.hubs, uuid)
+}
+
+// CloseDialog disconnects everyone from the dialog between client and target and forgets it.
+func (s *Server) CloseDialog(client, target string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if h, ok := s.hubs[client][target]; ok {
+		h.stop()
+	}
+	delete(s.hubs[client], target)
+	delete(s.hubs[target], client)
 }
 
 func (s *Server) GetAllChats(ctx context.Context, uuid string) ([]string, error) {
 	return s.store.GetAllChats(ctx, uuid)
 }
 
+// LoadMessages returns up to limit, but no more than MaxHistoryLimit, messages of the dialog sent before
+// the message with the given time and id. The history is shown only to users allowed to chat.
+func (s *Server) LoadMessages(ctx context.Context, client, target string, before time.Time, beforeID, limit int64) ([]*Message, error) { //nolint:lll
+	allowed, err := s.allow(ctx, client, target)
+	if err != nil {
+		return nil, fmt.Errorf("err checking relations between %s and %s: %w", client, target, err)
+	}
+	if !allowed {
+		return nil, common.ErrChatForbidden
+	}
+	if limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+	return s.store.LoadMessages(ctx, client, target, before, beforeID, limit)
+}
+
+// EachMessage calls fn for every message of the dialog between client and target, oldest first.
+func (s *Server) EachMessage(ctx context.Context, client, target string, fn func(m *Message) error) error {
+	return s.store.EachMessage(ctx, client, target, fn)
+}
+
 type Hub struct {
+	store      Store
+	uuid1      string
+	uuid2      string
 	clients    map[*Client]bool
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
+	done       chan struct{}
+	stopOnce   sync.Once
 }
 
-func newHub() *Hub {
+func newHub(store Store, uuid1, uuid2 string) *Hub {
 	return &Hub{
+		store:      store,
+		uuid1:      uuid1,
+		uuid2:      uuid2,
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		done:       make(chan struct{}),
 		clients:    make(map[*Client]bool),
 	}
 }
 
+// peer returns the other participant of the dialog.
+func (h *Hub) peer(uuid string) string {
+	if uuid == h.uuid1 {
+		return h.uuid2
+	}
+	return h.uuid1
+}
+
+// stop disconnects all clients of the hub and makes it ignore new ones.
+func (h *Hub) stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+	})
+}
+
 func (h *Hub) run() {
 	for {
 		select {
+		case <-h.done:
+			for client := range h.clients {
+				close(client.send)
+			}
+			return
 		case client := <-h.register:
 			h.clients[client] = true
 		case client := <-h.unregister:
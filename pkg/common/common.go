@@ -1,22 +1,11 @@
 package common
 
 import (
-	"errors"
 	"io"
 
 	"github.com/google/uuid"
 )
 
-var (
-	ErrConfigNotFound       = errors.New("config not found")
-	ErrUnauthenticated      = errors.New("err user failed to authenticate")
-	ErrGenderNotSpecified   = errors.New("err gender not specified")
-	ErrInvalidSigningMethod = errors.New("err invalid signing method")
-	ErrInvalidAccessToken   = errors.New("err invalid access token")
-	ErrInvalidPhoneNumber   = errors.New("err invalid phone number")
-	ErrPhoneNotFound        = errors.New("err phone not found")
-)
-
 func IsValidUUID(u string) bool {
 	_, err := uuid.Parse(u)
 	return err == nil
@@ -0,0 +1,79 @@
+package common
+
+import "net/http"
+
+// Error is an error that is safe to show to clients. Code is a stable machine-readable code,
+// Status is the HTTP status the error is reported with.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code string, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// Detailed is an Error with a detail shown to clients next to its message, like the reason
+// a request body can't be decoded. errors.Is matches it against the Error.
+type Detailed struct {
+	Err    *Error
+	Detail string
+}
+
+func (d *Detailed) Error() string {
+	return d.Err.Message + ": " + d.Detail
+}
+
+func (d *Detailed) Unwrap() error {
+	return d.Err
+}
+
+// WithDetail adds the detail to err.
+func WithDetail(err *Error, detail string) error {
+	return &Detailed{Err: err, Detail: detail}
+}
+
+var (
+	ErrInternal             = newError("internal", http.StatusInternalServerError, "internal server error")
+	ErrBadRequest           = newError("bad_request", http.StatusBadRequest, "malformed request")
+	ErrInvalidUUID          = newError("invalid_uuid", http.StatusBadRequest, "invalid uuid")
+	ErrConfigNotFound       = newError("config_not_found", http.StatusNotFound, "config not found")
+	ErrAccountDeleted       = newError("account_deleted", http.StatusGone, "account is deleted")
+	ErrUnauthenticated      = newError("unauthenticated", http.StatusUnauthorized, "user failed to authenticate")
+	ErrGenderNotSpecified   = newError("gender_not_specified", http.StatusBadRequest, "gender not specified")
+	ErrInvalidSigningMethod = newError("invalid_signing_method", http.StatusUnauthorized, "invalid signing method")
+	ErrInvalidAccessToken   = newError("invalid_access_token", http.StatusUnauthorized, "invalid access token")
+	ErrInvalidPhoneNumber   = newError("invalid_phone_number", http.StatusBadRequest, "invalid phone number")
+	ErrPhoneNotFound        = newError("phone_not_found", http.StatusNotFound, "phone not found")
+	ErrChatNotFound         = newError("chat_not_found", http.StatusNotFound, "chat not found")
+	ErrChatForbidden        = newError("chat_forbidden", http.StatusForbidden,
+		"chat is allowed only between mutually liked users")
+	ErrListingNotFound  = newError("listing_not_found", http.StatusNotFound, "listing not found")
+	ErrNotListingOwner  = newError("not_listing_owner", http.StatusForbidden, "only listing owners can publish listings")
+	ErrNothingToUndo    = newError("nothing_to_undo", http.StatusConflict, "nothing to undo")
+	ErrInvalidCursor    = newError("invalid_cursor", http.StatusBadRequest, "invalid cursor")
+	ErrInvalidPatch     = newError("invalid_patch", http.StatusBadRequest, "invalid merge patch")
+	ErrValidation       = newError("validation_failed", http.StatusUnprocessableEntity, "validation failed")
+	ErrPhotoNotFound    = newError("photo_not_found", http.StatusNotFound, "photo not found")
+	ErrPhotoTooLarge    = newError("photo_too_large", http.StatusRequestEntityTooLarge, "photo is too large")
+	ErrUnsupportedPhoto = newError("unsupported_photo", http.StatusUnsupportedMediaType,
+		"only jpeg and png photos are supported")
+	ErrTooManyPhotos     = newError("too_many_photos", http.StatusConflict, "gallery is full")
+	ErrInvalidPhotoOrder = newError("invalid_photo_order", http.StatusBadRequest,
+		"order must list every photo of the gallery once")
+	ErrInvalidReportReason = newError("invalid_report_reason", http.StatusBadRequest, "unknown report reason")
+	ErrInvalidResolution   = newError("invalid_resolution", http.StatusBadRequest, "unknown report resolution")
+	ErrReportNotFound      = newError("report_not_found", http.StatusNotFound, "unresolved report not found")
+	ErrSelfAction          = newError("self_action", http.StatusBadRequest, "users can't block or report themselves")
+	ErrInsufficientScope   = newError("insufficient_scope", http.StatusForbidden, "access token lacks the required scope")
+	ErrRegionNotFound      = newError("region_not_found", http.StatusNotFound, "region not found")
+	ErrInvalidRegion       = newError("invalid_region", http.StatusBadRequest,
+		"region name must not be empty, boundary must be a GeoJSON Polygon or MultiPolygon")
+	ErrInvalidRegionParent = newError("invalid_region_parent", http.StatusBadRequest,
+		"parent must be an existing region outside of the region")
+)
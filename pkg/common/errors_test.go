@@ -0,0 +1,75 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		err    *Error
+		code   string
+		status int
+	}{
+		{ErrInternal, "internal", http.StatusInternalServerError},
+		{ErrBadRequest, "bad_request", http.StatusBadRequest},
+		{ErrInvalidUUID, "invalid_uuid", http.StatusBadRequest},
+		{ErrConfigNotFound, "config_not_found", http.StatusNotFound},
+		{ErrAccountDeleted, "account_deleted", http.StatusGone},
+		{ErrUnauthenticated, "unauthenticated", http.StatusUnauthorized},
+		{ErrGenderNotSpecified, "gender_not_specified", http.StatusBadRequest},
+		{ErrInvalidSigningMethod, "invalid_signing_method", http.StatusUnauthorized},
+		{ErrInvalidAccessToken, "invalid_access_token", http.StatusUnauthorized},
+		{ErrInvalidPhoneNumber, "invalid_phone_number", http.StatusBadRequest},
+		{ErrPhoneNotFound, "phone_not_found", http.StatusNotFound},
+		{ErrChatNotFound, "chat_not_found", http.StatusNotFound},
+		{ErrChatForbidden, "chat_forbidden", http.StatusForbidden},
+		{ErrListingNotFound, "listing_not_found", http.StatusNotFound},
+		{ErrNotListingOwner, "not_listing_owner", http.StatusForbidden},
+		{ErrNothingToUndo, "nothing_to_undo", http.StatusConflict},
+		{ErrInvalidCursor, "invalid_cursor", http.StatusBadRequest},
+		{ErrInvalidPatch, "invalid_patch", http.StatusBadRequest},
+		{ErrValidation, "validation_failed", http.StatusUnprocessableEntity},
+		{ErrPhotoNotFound, "photo_not_found", http.StatusNotFound},
+		{ErrPhotoTooLarge, "photo_too_large", http.StatusRequestEntityTooLarge},
+		{ErrUnsupportedPhoto, "unsupported_photo", http.StatusUnsupportedMediaType},
+		{ErrTooManyPhotos, "too_many_photos", http.StatusConflict},
+		{ErrInvalidPhotoOrder, "invalid_photo_order", http.StatusBadRequest},
+		{ErrInvalidReportReason, "invalid_report_reason", http.StatusBadRequest},
+		{ErrInvalidResolution, "invalid_resolution", http.StatusBadRequest},
+		{ErrReportNotFound, "report_not_found", http.StatusNotFound},
+		{ErrSelfAction, "self_action", http.StatusBadRequest},
+		{ErrInsufficientScope, "insufficient_scope", http.StatusForbidden},
+		{ErrRegionNotFound, "region_not_found", http.StatusNotFound},
+		{ErrInvalidRegion, "invalid_region", http.StatusBadRequest},
+		{ErrInvalidRegionParent, "invalid_region_parent", http.StatusBadRequest},
+	}
+	codes := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			require.Equal(t, tt.code, tt.err.Code)
+			require.Equal(t, tt.status, tt.err.Status)
+			require.NotEmpty(t, tt.err.Message)
+			require.Equal(t, tt.err.Message, tt.err.Error())
+			require.False(t, codes[tt.code], "duplicate code")
+			codes[tt.code] = true
+
+			wrapped := fmt.Errorf("err doing something: %w", tt.err)
+			var domainErr *Error
+			require.True(t, errors.As(wrapped, &domainErr))
+			require.Same(t, tt.err, domainErr)
+		})
+	}
+}
+
+func TestWithDetail(t *testing.T) {
+	err := fmt.Errorf("err saving config: %w", WithDetail(ErrBadRequest, "unexpected EOF"))
+	require.ErrorIs(t, err, ErrBadRequest)
+	var detailed *Detailed
+	require.True(t, errors.As(err, &detailed))
+	require.Equal(t, "malformed request: unexpected EOF", detailed.Error())
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -21,6 +22,13 @@ func (c ContextKey) String() string {
 
 const ErrDescriptionCtxKey = ContextKey("errDescription")
 
+// SetErrDescription records the description of an error response, it is reported in RespErrorsTotal.
+func SetErrDescription(ctx context.Context, description string) {
+	if d, ok := ctx.Value(ErrDescriptionCtxKey).(*string); ok {
+		*d = description
+	}
+}
+
 func NewPromMiddleware(host string) func(next http.Handler) http.Handler {
 	ip, port := getExposedIPPort()
 	c := NewHTTPIn(host, ip, port).AutoRegister()
@@ -33,6 +41,8 @@ func NewPromMiddleware(host string) func(next http.Handler) http.Handler {
 			c.ReqTotal.WithLabelValues(labels...).Inc()
 			c.ReqBytesTotal.WithLabelValues(labels...).Add(float64(len(reqDump)))
 			wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			errDescription := "UNKNOWN"
+			r = r.WithContext(context.WithValue(r.Context(), ErrDescriptionCtxKey, &errDescription))
 
 			next.ServeHTTP(wrappedWriter, r)
 
@@ -44,10 +54,6 @@ func NewPromMiddleware(host string) func(next http.Handler) http.Handler {
 			if wrappedWriter.Status() < 500 {
 				return
 			}
-			errDescription, ok := r.Context().Value(ErrDescriptionCtxKey).(string)
-			if !ok {
-				errDescription = "UNKNOWN"
-			}
 			c.ReqErrorsTotal.WithLabelValues(labels...).Inc()
 			labels = append(labels, errDescription)
 			c.RespErrorsTotal.WithLabelValues(labels...).Inc()